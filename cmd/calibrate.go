@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/config"
+	"github.com/Z3DRP/zportfolio-service/internal/zypher"
+)
+
+// calibrate prints a zysettings block tuned for this machine, the configured settings are the starting point when there are any
+func calibrate(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	target := flags.Duration("target", 100*time.Millisecond, "how long a single zyph should take")
+	hasher := flags.String("hasher", "", "hasher to calibrate, defaults to the configured hasher")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var ops []func(*zypher.Zypher)
+	settings, err := config.ReadZypherSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read zysettings, calibrating the defaults: %v\n", err)
+	} else if ops, err = zypher.ConfigOps(settings); err != nil {
+		return err
+	}
+
+	if *hasher != "" {
+		hshr, err := zypher.HasherWithCosts(*hasher, zypher.ConfigCosts(settings))
+		if err != nil {
+			return err
+		}
+		ops = append(ops, zypher.WithHasher(hshr))
+	}
+
+	cal, err := zypher.Calibrate(*target, ops...)
+	if err != nil {
+		return err
+	}
+
+	params := cal.Params
+	host, _ := os.Hostname()
+	fmt.Fprintf(out, "# calibrated on %v for %v, a zyph took %v\n", host, *target, cal.Duration.Round(time.Microsecond))
+	if cal.Capped && params.HashIterCount > 1 {
+		fmt.Fprintf(out, "# hashCount is capped at %d, the most a stored hash may have, so %v falls short of the target\n", zypher.MaxIterCount, params.Hasher)
+	} else if cal.Capped {
+		fmt.Fprintf(out, "# the %v costs are capped at the most a stored hash may have, so it falls short of the target\n", params.Hasher)
+	} else if cal.Duration > 2**target {
+		// the costs scale about linearly, so this far over the target they could not go any lower
+		fmt.Fprintf(out, "# a single %v pass is slower than the target even at its lowest costs\n", params.Hasher)
+	}
+	fmt.Fprintln(out, "zysettings:")
+	fmt.Fprintf(out, "  shift: %d\n", params.Shift)
+	fmt.Fprintf(out, "  shiftCount: %d\n", params.ShiftIterCount)
+	fmt.Fprintf(out, "  hashCount: %d\n", params.HashIterCount)
+	fmt.Fprintf(out, "  alternate: %t\n", params.Alternate)
+	fmt.Fprintf(out, "  ignSpace: %t\n", params.IgnoreSpace)
+	fmt.Fprintf(out, "  restrictHash: %t\n", params.RestrictHashShift)
+	fmt.Fprintf(out, "  saltLength: %d\n", zypher.NewZypher(ops...).SaltLength)
+	fmt.Fprintf(out, "  hasher: %v\n", params.Hasher)
+	switch params.Hasher {
+	case zypher.Argon2id:
+		fmt.Fprintf(out, "  argonTime: %d\n", params.Costs.Time)
+		fmt.Fprintf(out, "  argonMemory: %d\n", params.Costs.Memory)
+		fmt.Fprintf(out, "  argonThreads: %d\n", params.Costs.Threads)
+	case zypher.Scrypt:
+		fmt.Fprintf(out, "  scryptN: %d\n", params.Costs.N)
+	case zypher.Bcrypt:
+		fmt.Fprintf(out, "  bcryptCost: %d\n", params.Costs.Cost)
+	}
+	fmt.Fprintln(out, "  # pepper is a secret, keep the one you have")
+	return nil
+}
@@ -0,0 +1,100 @@
+//go:build js && wasm
+
+// zypher-wasm runs the zypher package in the browser so the demo can hash client side and compare with the service,
+// build it with GOOS=js GOARCH=wasm go build -o web/zypher/zypher.wasm ./cmd/zypher-wasm
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/Z3DRP/zportfolio-service/internal/zypher"
+)
+
+func main() {
+	js.Global().Set("zypher", js.ValueOf(map[string]any{
+		"zyph":     zyphFunc(func(zy *zypher.Zypher) func(string) (string, error) { return zy.Zyph }),
+		"asciZyph": zyphFunc(func(zy *zypher.Zypher) func(string) (string, error) { return zy.AsciZyph }),
+		"hexZyph":  zyphFunc(func(zy *zypher.Zypher) func(string) (string, error) { return zy.HexZyph }),
+		"verify":   js.FuncOf(verify),
+	}))
+	// the exported funcs stop working once main returns
+	select {}
+}
+
+// zyphFunc wraps a zyph as zyph(text, options), options take the same fields as the POST /zypher body
+// and the result matches its response, {result, hasher} or {error}
+func zyphFunc(pick func(*zypher.Zypher) func(string) (string, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) == 0 || args[0].Type() != js.TypeString {
+			return errResult("expected the text to zyph")
+		}
+
+		options := js.Undefined()
+		if len(args) > 1 {
+			options = args[1]
+		}
+		zy, err := zypherFrom(options)
+		if err != nil {
+			return errResult(err.Error())
+		}
+
+		result, err := pick(zy)(args[0].String())
+		if err != nil {
+			return errResult(err.Error())
+		}
+		return map[string]any{"result": result, "hasher": zy.Params().Hasher}
+	})
+}
+
+// verify(encoded, text) answers {match} or {error}, a peppered hash can not be checked here since the pepper stays on the server
+func verify(this js.Value, args []js.Value) any {
+	if len(args) < 2 || args[0].Type() != js.TypeString || args[1].Type() != js.TypeString {
+		return errResult("expected an encoded hash and the text to verify")
+	}
+
+	ok, err := zypher.Verify(args[0].String(), args[1].String())
+	if err != nil {
+		return errResult(err.Error())
+	}
+	return map[string]any{"match": ok}
+}
+
+// zypherFrom builds a zypher from an options object, fields left out keep the zypher defaults
+func zypherFrom(options js.Value) (*zypher.Zypher, error) {
+	if options.Type() != js.TypeObject {
+		return zypher.NewZypher(), nil
+	}
+
+	var ops []func(*zypher.Zypher)
+	for field, op := range map[string]func(int) func(*zypher.Zypher){
+		"Shift":      zypher.WithShift,
+		"ShiftCount": zypher.WithShiftIterCount,
+		"HashCount":  zypher.WithHashIterCount,
+	} {
+		if v := options.Get(field); v.Type() == js.TypeNumber {
+			ops = append(ops, op(v.Int()))
+		}
+	}
+	for field, op := range map[string]func(bool) func(*zypher.Zypher){
+		"Alternate":    zypher.WithAlternate,
+		"IgnoreSpace":  zypher.WithIgnoreSpace,
+		"RestrictHash": zypher.WithRestrictedHashShift,
+		"Lossless":     zypher.WithLosslessSpace,
+	} {
+		if v := options.Get(field); v.Type() == js.TypeBoolean {
+			ops = append(ops, op(v.Bool()))
+		}
+	}
+	if v := options.Get("Hasher"); v.Type() == js.TypeString {
+		hasher, err := zypher.LookupHasher(v.String())
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, zypher.WithHasher(hasher))
+	}
+	return zypher.NewZypher(ops...), nil
+}
+
+func errResult(msg string) map[string]any {
+	return map[string]any{"error": msg}
+}
@@ -0,0 +1,182 @@
+// zypher hashes and verifies from the command line with the same zysettings as the service
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Z3DRP/zportfolio-service/config"
+	"github.com/Z3DRP/zportfolio-service/internal/zypher"
+)
+
+const usage = `usage: zypher [flags] [text ...]
+  prints the digest of the text, or of every line read from stdin when no text is given
+  -encode prints a salted encoded hash instead, for storing passwords
+  -verify <encoded> checks the text, or every line of stdin, against an encoded hash and exits with 1 when any does not match
+flags default to the zysettings block of ./config/config.yml when there is one
+`
+
+// output is one line of -json output
+type output struct {
+	Result string `json:"result,omitempty"`
+	Hasher string `json:"hasher,omitempty"`
+	Mode   string `json:"mode,omitempty"`
+	Match  *bool  `json:"match,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var ops []func(*zypher.Zypher)
+	settings, err := config.ReadZypherSettings()
+	if err == nil {
+		ops, err = zypher.ConfigOps(settings)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "could not read zysettings, using the zypher defaults: %v\n", err)
+		ops = nil
+	}
+	defaults := zypher.NewZypher(ops...)
+
+	flags := flag.NewFlagSet("zypher", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	shift := flags.Int("shift", defaults.Shift, "how far each character is shifted")
+	shiftIter := flags.Int("shift-iter", defaults.ShiftIterCount, "how many times the text is shifted")
+	hashIter := flags.Int("hash-iter", defaults.HashIterCount, "how many times the shifted text is hashed")
+	alternate := flags.Bool("alternate", defaults.Alternate, "alternate the shift direction between characters")
+	ignoreSpace := flags.Bool("ignore-space", defaults.IgnoreSpace, "leave spaces unshifted")
+	restrictHash := flags.Bool("restrict-hash", defaults.RestrictHashShift, "keep shifted characters within hex digits")
+	lossless := flags.Bool("lossless", defaults.LosslessSpace, "shift spaces to ~ so they survive an unzyph, only used with -mode asci")
+	saltLength := flags.Int("salt-length", defaults.SaltLength, "salt length in bytes for -encode")
+	hasher := flags.String("hasher", defaults.Params().Hasher, fmt.Sprintf("digest for the hash rounds, one of %s", strings.Join(zypher.HasherNames(), ", ")))
+	mode := flags.String("mode", "", "asci or hex only runs the reversible shift stage")
+	encode := flags.Bool("encode", false, "print a salted encoded hash")
+	verify := flags.String("verify", "", "encoded hash to check the text against")
+	asJson := flags.Bool("json", false, "print one json object per result")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	hshr, err := zypher.LookupHasher(*hasher)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	zy := zypher.NewZypher(append(ops,
+		zypher.WithShift(*shift),
+		zypher.WithShiftIterCount(*shiftIter),
+		zypher.WithHashIterCount(*hashIter),
+		zypher.WithAlternate(*alternate),
+		zypher.WithIgnoreSpace(*ignoreSpace),
+		zypher.WithRestrictedHashShift(*restrictHash),
+		zypher.WithLosslessSpace(*lossless),
+		zypher.WithSaltLength(*saltLength),
+		zypher.WithHasher(hshr),
+	)...)
+
+	inputs := []string{strings.Join(flags.Args(), " ")}
+	if flags.NArg() == 0 {
+		if inputs, err = readLines(stdin); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	write := func(out output) {
+		if *asJson {
+			json.NewEncoder(stdout).Encode(out)
+		} else if out.Error != "" {
+			fmt.Fprintln(stderr, out.Error)
+		} else if out.Match != nil {
+			fmt.Fprintln(stdout, *out.Match)
+		} else {
+			fmt.Fprintln(stdout, out.Result)
+		}
+	}
+
+	if *verify != "" {
+		// a malformed hash would fail every line the same way
+		if _, err := zypher.ParseEncoded(*verify); err != nil {
+			write(output{Error: err.Error()})
+			return 1
+		}
+
+		// every line gets its own match, the exit status is 1 when any of them does not match
+		status := 0
+		for _, input := range inputs {
+			// the pepper in zysettings is the only option an encoded hash does not carry
+			ok, err := zypher.Verify(*verify, input, zypher.WithPepper(settings.Pepper))
+			if err != nil {
+				write(output{Error: err.Error()})
+				status = 1
+				continue
+			}
+			write(output{Match: &ok})
+			if !ok {
+				status = 1
+			}
+		}
+		return status
+	}
+
+	zyph := zy.Zyph
+	switch {
+	case *mode == "asci":
+		zyph = zy.AsciZyph
+	case *mode == "hex":
+		zyph = zy.HexZyph
+	case *mode != "":
+		fmt.Fprintln(stderr, "unknown mode, expected asci or hex")
+		return 2
+	case *encode:
+		zyph = zy.Hash
+	}
+
+	status := 0
+	for _, input := range inputs {
+		result, err := zyph(input)
+		if err != nil {
+			write(output{Error: err.Error()})
+			status = 1
+			continue
+		}
+		if *mode != "" {
+			write(output{Result: result, Mode: *mode})
+		} else {
+			write(output{Result: result, Hasher: hshr.Name()})
+		}
+	}
+	return status
+}
+
+// readLines reads every line of stdin so a list of inputs can be piped in, a trailing carriage return is dropped
+func readLines(stdin io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("nothing to hash, pass the text as arguments or on stdin")
+	}
+	return lines, nil
+}
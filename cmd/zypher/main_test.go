@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Z3DRP/zportfolio-service/internal/zypher"
+)
+
+// zypherRun runs the command the way main does and returns its exit status, stdout and stderr
+func zypherRun(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+// errorLines counts the stderr lines other than the notice printed when there is no config to read
+func errorLines(errOut string) int {
+	count := 0
+	for _, line := range strings.Split(strings.TrimSpace(errOut), "\n") {
+		if line != "" && !strings.HasPrefix(line, "could not read zysettings") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRunDigest(t *testing.T) {
+	want, err := zypher.NewZypher(zypher.WithShift(3)).Zyph("some text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, out, _ := zypherRun(t, "", "-shift", "3", "some", "text")
+	if status != 0 || out != want+"\n" {
+		t.Errorf("got status %d and %q, wanted 0 and %q", status, out, want)
+	}
+}
+
+func TestRunLines(t *testing.T) {
+	status, out, _ := zypherRun(t, "first\r\nsecond\n", "-mode", "asci")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if status != 0 || len(lines) != 2 {
+		t.Fatalf("got status %d and %q, wanted a result for each line", status, out)
+	}
+
+	for i, input := range []string{"first", "second"} {
+		want, _ := zypher.NewZypher().AsciZyph(input)
+		if lines[i] != want {
+			t.Errorf("got %q for %q, wanted %q", lines[i], input, want)
+		}
+	}
+}
+
+func TestRunVerifyEveryLine(t *testing.T) {
+	status, encoded, _ := zypherRun(t, "", "-encode", "hunter2")
+	encoded = strings.TrimSpace(encoded)
+	if status != 0 || encoded == "" {
+		t.Fatalf("got status %d and %q, wanted an encoded hash", status, encoded)
+	}
+
+	cases := []struct {
+		name   string
+		stdin  string
+		out    string
+		status int
+	}{
+		{"all match", "hunter2\nhunter2\n", "true\ntrue\n", 0},
+		{"later line wrong", "hunter2\nhunter3\n", "true\nfalse\n", 1},
+		{"first line wrong", "hunter3\nhunter2\n", "false\ntrue\n", 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			status, out, _ := zypherRun(t, c.stdin, "-verify", encoded)
+			if status != c.status || out != c.out {
+				t.Errorf("got status %d and %q, wanted %d and %q", status, out, c.status, c.out)
+			}
+		})
+	}
+
+	status, out, _ := zypherRun(t, "hunter2\nnope\n", "-json", "-verify", encoded)
+	var matches []bool
+	dec := json.NewDecoder(strings.NewReader(out))
+	for dec.More() {
+		var line output
+		if err := dec.Decode(&line); err != nil || line.Match == nil {
+			t.Fatalf("got %q, wanted a match on every json line", out)
+		}
+		matches = append(matches, *line.Match)
+	}
+	if status != 1 || len(matches) != 2 || !matches[0] || matches[1] {
+		t.Errorf("got status %d and matches %v, wanted 1 and [true false]", status, matches)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		stdin  string
+		args   []string
+		status int
+	}{
+		{"malformed hash", "hunter2\n", []string{"-verify", "not-a-hash"}, 1},
+		{"unknown mode", "", []string{"-mode", "rot13", "text"}, 2},
+		{"unknown hasher", "", []string{"-hasher", "md5", "text"}, 2},
+		{"nothing to hash", "", nil, 1},
+		{"invalid line", "ok\nnot ok!\n", []string{"-mode", "asci"}, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			status, _, errOut := zypherRun(t, c.stdin, c.args...)
+			if status != c.status || errorLines(errOut) == 0 {
+				t.Errorf("got status %d and stderr %q, wanted %d with an error", status, errOut, c.status)
+			}
+		})
+	}
+}
@@ -60,6 +60,7 @@ type Configurations struct {
 	DatabaseStore  DbStoreConfig `mapstructure:"database"`
 	ZypherSettings ZypherConfig  `mapstructure:"zysettings"`
 	ZEmailSettings ZEmailConfig  `mapstructure:"zemailsettings"`
+	ZAdmin         ZAdminConfig  `mapstructure:"zadmin"`
 }
 
 type ZServerConfig struct {
@@ -85,12 +86,21 @@ type DbStoreConfig struct {
 }
 
 type ZypherConfig struct {
-	Shift        int  `mapstructure:"shift"`
-	ShiftCount   int  `mapstructure:"shiftCount"`
-	HashCount    int  `mapstructure:"hashCount"`
-	Alternate    bool `mapstructure:"alternate"`
-	IgnSpace     bool `mapstructure:"ignSpace"`
-	RestrictHash bool `mapstructure:"restrictHash"`
+	Shift        int    `mapstructure:"shift"`
+	ShiftCount   int    `mapstructure:"shiftCount"`
+	HashCount    int    `mapstructure:"hashCount"`
+	Alternate    bool   `mapstructure:"alternate"`
+	IgnSpace     bool   `mapstructure:"ignSpace"`
+	RestrictHash bool   `mapstructure:"restrictHash"`
+	SaltLength   int    `mapstructure:"saltLength"`
+	Pepper       string `mapstructure:"pepper"`
+	Hasher       string `mapstructure:"hasher"`
+	ArgonTime    int    `mapstructure:"argonTime"`
+	ArgonMemory  int    `mapstructure:"argonMemory"` // KiB
+	ArgonThreads int    `mapstructure:"argonThreads"`
+	ScryptN      int    `mapstructure:"scryptN"`
+	BcryptCost   int    `mapstructure:"bcryptCost"`
+	QueryInput   bool   `mapstructure:"queryInput"` // lets POST /zypher still read its input from query params
 }
 
 type ZEmailConfig struct {
@@ -101,6 +111,10 @@ type ZEmailConfig struct {
 	SmtpPort        int    `mapstructure:"smtpPort"`
 }
 
+type ZAdminConfig struct {
+	ApiKey string `mapstructure:"apiKey"` // bearer token for the admin endpoints, admin routes are disabled when empty
+}
+
 func ReadServerConfig() (*ZServerConfig, error) {
 	viper.SetConfigType("yaml")
 	viper.SetConfigName("config")
@@ -188,6 +202,28 @@ func ReadEmailConfig() (*ZEmailConfig, error) {
 	return &configs.ZEmailSettings, nil
 }
 
+func ReadAdminConfig() (*ZAdminConfig, error) {
+	viper.SetConfigType("yaml")
+	viper.SetConfigName("config")
+	viper.AddConfigPath("./config")
+	viper.AutomaticEnv()
+	var configs Configurations
+
+	if err := viper.ReadInConfig(); err != nil {
+		emsg := fmt.Sprintf("error reading config file, %v", err)
+		logger.MustDebug(emsg)
+		return nil, errors.New(emsg)
+	}
+
+	err := viper.Unmarshal(&configs)
+	if err != nil {
+		emsg := fmt.Sprintf("unable to decode config to json:: %v", err)
+		logger.MustDebug(emsg)
+		return nil, errors.New(emsg)
+	}
+	return &configs.ZAdmin, nil
+}
+
 func IsValidOrigin(origin string) bool {
 	return true
 	//	validOrigin := map[string]bool{
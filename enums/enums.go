@@ -98,6 +98,24 @@ func (tt TaskType) Index() int {
 	return int(tt)
 }
 
+// TaskStatus zero value is Requested so tasks stored before statuses existed read as waiting on the owner
+type TaskStatus int
+
+const (
+	Requested TaskStatus = iota
+	Accepted
+	Declined
+	Blocked
+)
+
+func (ts TaskStatus) String() string {
+	return [...]string{"Requested", "Accepted", "Declined", "Blocked"}[ts]
+}
+
+func (ts TaskStatus) Index() int {
+	return int(ts)
+}
+
 type PeriodType int
 
 const (
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/dtos"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+	zyp "github.com/Z3DRP/zportfolio-service/internal/zypher"
+)
+
+const (
+	AnalysisRunning = "running"
+	AnalysisDone    = "done"
+	AnalysisFailed  = "failed"
+
+	maxRunningAnalyses = 2
+	analysisTimeout    = 2 * time.Minute
+	// finished jobs are kept this long for the results endpoint
+	analysisJobTTL = time.Hour
+)
+
+var (
+	ErrAnalysisBusy     = errors.New("too many analyses are running, try again later")
+	ErrAnalysisNotFound = errors.New("analysis not found")
+)
+
+// analysis jobs are only kept in memory, they are a demo and cheap to run again after a restart
+var analyses = struct {
+	sync.Mutex
+	jobs    map[string]*dtos.ZypherAnalysisJobDto
+	running int
+}{jobs: make(map[string]*dtos.ZypherAnalysisJobDto)}
+
+// StartAnalysis checks the request and runs the analysis in the background, the returned job is polled with FetchAnalysis
+func StartAnalysis(req dtos.ZypherAnalysisRequest) (dtos.ZypherAnalysisJobDto, error) {
+	opts := zyp.AnalysisOptions{Corpus: req.Corpus, Size: req.Size, Seed: req.Seed}
+	if err := opts.Validate(); err != nil {
+		return dtos.ZypherAnalysisJobDto{}, err
+	}
+
+	ops, err := zypherOps(dtos.ZypherRequest{
+		Shift:        req.Shift,
+		ShiftCount:   req.ShiftCount,
+		HashCount:    req.HashCount,
+		Alternate:    req.Alternate,
+		IgnoreSpace:  req.IgnoreSpace,
+		RestrictHash: req.RestrictHash,
+		Hasher:       req.Hasher,
+	})
+	if err != nil {
+		return dtos.ZypherAnalysisJobDto{}, err
+	}
+
+	zypher := zyp.NewZypher(ops...)
+	if !zypher.Hasher.Iterative() {
+		return dtos.ZypherAnalysisJobDto{}, ErrKdfPerInput
+	}
+
+	rawId, err := utils.GenToken()
+	if err != nil {
+		return dtos.ZypherAnalysisJobDto{}, utils.NewIdGenErr("analysis id", err)
+	}
+	job := &dtos.ZypherAnalysisJobDto{
+		Id:      hex.EncodeToString(rawId[:16]),
+		Status:  AnalysisRunning,
+		Created: time.Now(),
+	}
+
+	analyses.Lock()
+	defer analyses.Unlock()
+	pruneAnalyses(job.Created)
+	if analyses.running >= maxRunningAnalyses {
+		return dtos.ZypherAnalysisJobDto{}, ErrAnalysisBusy
+	}
+	analyses.running++
+	analyses.jobs[job.Id] = job
+
+	go runAnalysis(job.Id, *zypher, opts)
+	return *job, nil
+}
+
+func FetchAnalysis(id string) (dtos.ZypherAnalysisJobDto, error) {
+	analyses.Lock()
+	defer analyses.Unlock()
+	job, ok := analyses.jobs[id]
+	if !ok {
+		return dtos.ZypherAnalysisJobDto{}, ErrAnalysisNotFound
+	}
+	return *job, nil
+}
+
+func runAnalysis(id string, zypher zyp.Zypher, opts zyp.AnalysisOptions) {
+	ctx, cancel := context.WithTimeout(context.Background(), analysisTimeout)
+	defer cancel()
+	result, err := zyp.Analyze(ctx, zypher, opts)
+
+	analyses.Lock()
+	defer analyses.Unlock()
+	analyses.running--
+	job := analyses.jobs[id]
+	job.Finished = time.Now()
+	if err != nil {
+		job.Status, job.Error = AnalysisFailed, err.Error()
+		return
+	}
+	job.Status, job.Result = AnalysisDone, result
+}
+
+// pruneAnalyses drops finished jobs older than the ttl, callers hold the lock
+func pruneAnalyses(now time.Time) {
+	for id, job := range analyses.jobs {
+		if job.Status != AnalysisRunning && now.Sub(job.Finished) > analysisJobTTL {
+			delete(analyses.jobs, id)
+		}
+	}
+}
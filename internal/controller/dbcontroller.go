@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Z3DRP/zportfolio-service/config"
+	"github.com/Z3DRP/zportfolio-service/enums"
 	"github.com/Z3DRP/zportfolio-service/internal/dacstore"
 	"github.com/Z3DRP/zportfolio-service/internal/models"
 	"github.com/Z3DRP/zportfolio-service/internal/utils"
@@ -137,6 +138,30 @@ func FetchSchedule(ctx context.Context, start, end time.Time) (models.Responser,
 }
 
 func CreateTask(ctx context.Context, start, end time.Time, details string, usrId string) (models.Responser, error) {
+	return insertTask(ctx, start, end, details, usrId, enums.Requested)
+}
+
+// FetchOverlappingTasks returns the stored tasks that would clash with a new task from start to end
+func FetchOverlappingTasks(ctx context.Context, start, end time.Time) (models.Tasklist, error) {
+	tskStore, err := dacstore.CreateTaskStore(ctx)
+	if err != nil {
+		logger.MustDebug(fmt.Sprintf("error creating task store: %v", err))
+		return nil, fmt.Errorf("failed to create task store:: %w", err)
+	}
+
+	tasks, err := tskStore.FetchOverlappingTasks(ctx, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dacstore.ErrFetchTask, err)
+	}
+	return tasks, nil
+}
+
+// BlockSlot stores an owner task that marks the range as unavailable
+func BlockSlot(ctx context.Context, start, end time.Time, details string, ownerId string) (models.Responser, error) {
+	return insertTask(ctx, start, end, details, ownerId, enums.Blocked)
+}
+
+func insertTask(ctx context.Context, start, end time.Time, details string, usrId string, status enums.TaskStatus) (models.Responser, error) {
 	tskStore, err := dacstore.CreateTaskStore(ctx)
 	if err != nil {
 		logger.MustDebug(fmt.Sprintf("error creating task store: %v", err))
@@ -149,7 +174,7 @@ func CreateTask(ctx context.Context, start, end time.Time, details string, usrId
 		return nil, fmt.Errorf("failed to generate TID:: %v", err)
 	}
 
-	task := models.BuildTask(models.WithTimes(start, end), models.WithDetail(details), models.WithUser(usrId), models.WithTid(tid))
+	task := models.BuildTask(models.WithTimes(start, end), models.WithDetail(details), models.WithUser(usrId), models.WithTid(tid), models.WithStatus(status))
 	result, err := tskStore.Insert(ctx, *task)
 
 	if err != nil {
@@ -200,17 +225,19 @@ func EditTask(ctx context.Context, tid, uid string, start, end time.Time, detail
 
 	}
 
-	if tsk, ok := task.(models.Task); ok {
-		if tsk.User != uid {
-			logger.MustDebug("update action not allowed user must own task")
-			return nil, fmt.Errorf("action not allowed user must own task")
-		}
-	} else {
+	// FetchTask hands back a *Task
+	tsk, ok := task.(*models.Task)
+	if !ok {
 		logger.MustDebug(fmt.Sprintf("could not cast Type[%T] as Task for update", task))
 		return nil, fmt.Errorf("could not cast Type[%T] as Task for update", task)
 	}
 
-	updatedTask := models.Task{StartTime: start, EndTime: end, Detail: detail}
+	if tsk.User != uid {
+		logger.MustDebug("update action not allowed user must own task")
+		return nil, fmt.Errorf("action not allowed user must own task")
+	}
+
+	updatedTask := models.Task{Id: tsk.Id, StartTime: start, EndTime: end, Detail: detail, Method: tsk.Method, Tid: tid, User: uid, Status: tsk.Status}
 	matchedCount, updatedCount, err := tskStore.UpdateTask(ctx, tid, &updatedTask)
 
 	if err != nil {
@@ -221,7 +248,9 @@ func EditTask(ctx context.Context, tid, uid string, start, end time.Time, detail
 		return nil, fmt.Errorf("unknown error incorrect update count")
 	}
 
-	return models.NewTaskEditResponse(matchedCount, updatedCount, updatedTask), nil
+	res := models.NewTaskEditResponse(matchedCount, updatedCount, updatedTask)
+	res.Previous = *tsk
+	return res, nil
 }
 
 func RemoveTask(ctx context.Context, tid, uid string) (models.TaskDeleteResponse, error) {
@@ -242,23 +271,57 @@ func RemoveTask(ctx context.Context, tid, uid string) (models.TaskDeleteResponse
 		return models.TaskDeleteResponse{}, fmt.Errorf("could not read task for delete:: %v", err)
 	}
 
-	if tsk, ok := task.(models.Task); ok {
-		if tsk.User != uid {
-			logger.MustDebug("delete action not allowed user must own task")
-			return models.TaskDeleteResponse{}, fmt.Errorf("action not allowed user must own task")
-		}
-	} else {
+	tsk, ok := task.(*models.Task)
+	if !ok {
 		logger.MustDebug(fmt.Sprintf("could not cast Type[%T] as Task for delete", task))
 		return models.TaskDeleteResponse{}, fmt.Errorf("could not cast Type[%T] as Task for delete", task)
 	}
 
+	if tsk.User != uid {
+		logger.MustDebug("delete action not allowed user must own task")
+		return models.TaskDeleteResponse{}, fmt.Errorf("action not allowed user must own task")
+	}
+
 	delCount, err := taskStore.DeleteTask(ctx, tid)
 	if err != nil {
 		logger.MustDebug(fmt.Sprintf("error deleting task task:: %v", err))
 		return models.TaskDeleteResponse{}, fmt.Errorf("failed to delete task:: %w", err)
 	}
 
-	return models.NewTaskDeleteResponse(tid, delCount), nil
+	return models.NewTaskDeleteResponse(tid, delCount, *tsk), nil
+}
+
+// SetTaskStatus is the owner accepting or declining a request, ownership is not checked because only the owner can call it
+func SetTaskStatus(ctx context.Context, tid string, status enums.TaskStatus) (models.Responser, error) {
+	tskStore, err := dacstore.CreateTaskStore(ctx)
+	if err != nil {
+		logger.MustDebug(fmt.Sprintf("error creating task store:: %v", err))
+		return nil, fmt.Errorf("failed to create task store:: %w", err)
+	}
+
+	task, err := tskStore.FetchTask(ctx, tid)
+	if err != nil {
+		logger.MustDebug(fmt.Sprintf("could not read task for status update:: %v", err))
+		return nil, fmt.Errorf("could not read task for status update:: %v", err)
+	}
+
+	tsk, ok := task.(*models.Task)
+	if !ok {
+		logger.MustDebug(fmt.Sprintf("could not cast Type[%T] as Task for status update", task))
+		return nil, fmt.Errorf("could not cast Type[%T] as Task for status update", task)
+	}
+
+	matchedCount, updatedCount, err := tskStore.UpdateTaskStatus(ctx, tid, status)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while updating task status:: %w", err)
+	}
+
+	if matchedCount != 1 {
+		return nil, fmt.Errorf("could not find task with ID: %v", tid)
+	}
+
+	tsk.Status = status
+	return models.NewTaskEditResponse(matchedCount, updatedCount, *tsk), nil
 }
 
 func CreateVisitor(ctx context.Context, visitCount int, uid, addr string, hasCreatedTask bool) (models.Responser, error) {
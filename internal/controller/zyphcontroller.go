@@ -1,8 +1,27 @@
 package controller
 
-import zyp "github.com/Z3DRP/zportfolio-service/internal/zypher"
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/dtos"
+	zyp "github.com/Z3DRP/zportfolio-service/internal/zypher"
+)
+
+// CalculateZypherWithHasher returns the digest along with the name of the hasher used, an empty hasher is the default sha512
+func CalculateZypherWithHasher(txt string, shft, shftCount, hshCount int, alt, ignSpc, restcHsh bool, hasher string) (string, string, error) {
+	hshr, err := zyp.LookupHasher(hasher)
+	if err != nil {
+		return "", "", err
+	}
 
-func CalculateZypher(txt string, shft, shftCount, hshCount int, alt, ignSpc, restcHsh bool) (string, error) {
 	zypher := zyp.NewZypher(
 		zyp.WithShift(shft),
 		zyp.WithShiftIterCount(shftCount),
@@ -10,11 +29,320 @@ func CalculateZypher(txt string, shft, shftCount, hshCount int, alt, ignSpc, res
 		zyp.WithAlternate(alt),
 		zyp.WithIgnoreSpace(ignSpc),
 		zyp.WithRestrictedHashShift(restcHsh),
+		zyp.WithHasher(hshr),
 	)
 
 	result, err := zypher.Zyph(txt)
+	if err != nil {
+		return "", "", err
+	}
+	return result, hshr.Name(), nil
+}
+
+const (
+	ShiftModeAsci = "asci"
+	ShiftModeHex  = "hex"
+)
+
+var ErrUnknownShiftMode = errors.New("unknown shift mode, expected asci or hex")
+
+const (
+	MaxZypherBatch = 100
+	// every input in a request shares this much zyph time, a zyph still running when it runs out is stopped
+	// and inputs left after that fail instead of holding a worker
+	zypherBudget = 2 * time.Second
+)
+
+var (
+	ErrZypherBatchSize = fmt.Errorf("too many inputs, at most %d are allowed", MaxZypherBatch)
+	ErrZypherBudget    = errors.New("the request used up its zypher time")
+	ErrZypherCount     = fmt.Errorf("shift and hash counts must be between 0 and %d", zyp.MaxIterCount)
+	// a kdf pass costs megabytes of memory, requests that would run one per input are limited to the digests
+	ErrKdfPerInput = errors.New("argon2id, scrypt and bcrypt only hash a single input per request, use a digest hasher for more")
+)
+
+// zypherWorkers is shared by every request so zyphs never run on more goroutines than there are cpus
+var zypherWorkers = make(chan struct{}, runtime.NumCPU())
+
+// zypherOps turns a request into options, counts left out of the request keep the zypher defaults
+func zypherOps(req dtos.ZypherRequest) ([]func(*zyp.Zypher), error) {
+	hshr, err := zyp.LookupHasher(req.Hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []func(*zyp.Zypher){
+		zyp.WithAlternate(req.Alternate),
+		zyp.WithIgnoreSpace(req.IgnoreSpace),
+		zyp.WithRestrictedHashShift(req.RestrictHash),
+		zyp.WithLosslessSpace(req.Lossless),
+		zyp.WithHasher(hshr),
+	}
+	if req.Shift != nil {
+		ops = append(ops, zyp.WithShift(*req.Shift))
+	}
+	for _, count := range []struct {
+		value *int
+		op    func(int) func(*zyp.Zypher)
+	}{{req.ShiftCount, zyp.WithShiftIterCount}, {req.HashCount, zyp.WithHashIterCount}} {
+		if count.value == nil {
+			continue
+		}
+		if *count.value < 0 || *count.value > zyp.MaxIterCount {
+			return nil, fmt.Errorf("%w, got %d", ErrZypherCount, *count.value)
+		}
+		ops = append(ops, count.op(*count.value))
+	}
+	return ops, nil
+}
+
+// zyphFunc picks what a request runs, a mode only runs the reversible shift stage so its result can be sent to /unzyph
+func zyphFunc(zypher *zyp.Zypher, mode string) (func(string) (string, error), error) {
+	switch mode {
+	case "":
+		return zypher.Zyph, nil
+	case ShiftModeAsci:
+		return zypher.AsciZyph, nil
+	case ShiftModeHex:
+		return zypher.HexZyph, nil
+	}
+	return nil, ErrUnknownShiftMode
+}
+
+// CalculateZypherRequest zyphs the single Txt input of a request, it returns the name of the hasher used with the result
+func CalculateZypherRequest(ctx context.Context, req dtos.ZypherRequest) (string, string, error) {
+	ops, err := zypherOps(req)
+	if err != nil {
+		return "", "", err
+	}
+
+	zypher := zyp.NewZypher(ops...)
+	if _, err := zyphFunc(zypher, req.Mode); err != nil {
+		return "", "", err
+	}
+
+	result, err := zyphOnWorker(ctx, *zypher, req.Mode, req.Txt, new(atomic.Int64))
+	return result, zypher.Params().Hasher, err
+}
+
+// CalculateZypherBatch zyphs every input of a request on the shared workers, results keep the order of the inputs
+// and an input that fails only fails its own result
+func CalculateZypherBatch(ctx context.Context, req dtos.ZypherRequest) (*dtos.ZypherBatchDto, error) {
+	if len(req.Inputs) > MaxZypherBatch {
+		return nil, ErrZypherBatchSize
+	}
+
+	ops, err := zypherOps(req)
+	if err != nil {
+		return nil, err
+	}
+
+	zypher := zyp.NewZypher(ops...)
+	if !zypher.Hasher.Iterative() {
+		return nil, ErrKdfPerInput
+	}
+	if _, err := zyphFunc(zypher, req.Mode); err != nil {
+		return nil, err
+	}
+
+	results := make([]dtos.ZypherItemDto, len(req.Inputs))
+	jobs := make(chan int)
+	var spent atomic.Int64
+	var wg sync.WaitGroup
+
+	for i := 0; i < min(len(req.Inputs), cap(zypherWorkers)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for indx := range jobs {
+				result, err := zyphOnWorker(ctx, *zypher, req.Mode, req.Inputs[indx], &spent)
+				results[indx] = dtos.NewZypherItemDto(result, err)
+			}
+		}()
+	}
+
+	for indx := range req.Inputs {
+		jobs <- indx
+	}
+	close(jobs)
+	wg.Wait()
+
+	return &dtos.ZypherBatchDto{Results: results, Hasher: zypher.Params().Hasher, Mode: req.Mode}, nil
+}
+
+// zyphOnWorker zyphs input in mode once a shared worker is free
+func zyphOnWorker(ctx context.Context, zypher zyp.Zypher, mode, input string, spent *atomic.Int64) (string, error) {
+	var result string
+	err := onWorker(ctx, &zypher, spent, func() error {
+		zyph, err := zyphFunc(&zypher, mode)
+		if err != nil {
+			return err
+		}
+		result, err = zyph(input)
+		return err
+	})
+	return result, err
+}
+
+// onWorker waits for a shared worker and runs run with zypher stopped once the request's budget is spent,
+// spent is the time the request has used so far and is shared by every input of a batch
+func onWorker(ctx context.Context, zypher *zyp.Zypher, spent *atomic.Int64, run func() error) error {
+	select {
+	case zypherWorkers <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-zypherWorkers }()
+
+	remaining := zypherBudget - time.Duration(spent.Load())
+	if remaining <= 0 {
+		return ErrZypherBudget
+	}
+	budgetCtx, cancel := context.WithTimeout(ctx, remaining)
+	defer cancel()
+	zyp.WithContext(budgetCtx)(zypher)
+
+	start := time.Now()
+	err := run()
+	spent.Add(int64(time.Since(start)))
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return ErrZypherBudget
+	}
+	return err
+}
+
+// CalculateUnzyph reverses the shift stage of the single Txt input of a request, it is held to the same counts,
+// workers and budget as a zyph. An empty mode is asci
+func CalculateUnzyph(ctx context.Context, req dtos.ZypherRequest) (string, error) {
+	ops, err := zypherOps(req)
 	if err != nil {
 		return "", err
 	}
-	return result, nil
+
+	zypher := zyp.NewZypher(ops...)
+	if _, err := unzyphFunc(zypher, req.Mode); err != nil {
+		return "", err
+	}
+
+	var result string
+	err = onWorker(ctx, zypher, new(atomic.Int64), func() error {
+		// picked once the worker has set the context, the methods copy the zypher they are bound to
+		unzyph, err := unzyphFunc(zypher, req.Mode)
+		if err != nil {
+			return err
+		}
+		result, err = unzyph(req.Txt)
+		return err
+	})
+	return result, err
+}
+
+func unzyphFunc(zypher *zyp.Zypher, mode string) (func(string) (string, error), error) {
+	switch mode {
+	case ShiftModeAsci, "":
+		return zypher.AsciUnzyph, nil
+	case ShiftModeHex:
+		return zypher.HexUnzyph, nil
+	}
+	return nil, ErrUnknownShiftMode
+}
+
+// ExplainZypher runs the same zyph as CalculateZypherRequest, with the same budget, and records every step
+func ExplainZypher(ctx context.Context, req dtos.ZypherRequest) (*dtos.ZypherExplainDto, error) {
+	ops, err := zypherOps(req)
+	if err != nil {
+		return nil, err
+	}
+
+	trace := &zyp.Trace{}
+	zypher := zyp.NewZypher(append(ops, zyp.WithTrace(trace))...)
+	if _, err := zyphFunc(zypher, req.Mode); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	result, err := zyphOnWorker(ctx, *zypher, req.Mode, req.Txt, new(atomic.Int64))
+	if err != nil {
+		return nil, err
+	}
+
+	explained := dtos.NewZypherExplainDto(result, req.Mode, *zypher, trace, time.Since(start))
+	return &explained, nil
+}
+
+// ZypherReader streams rdr through a zypher writer with the default settings, an empty hasher is the default sha512.
+// Only the digest hashers stream, any other fails on the first chunk read
+func ZypherReader(rdr io.Reader, hasher string) (*dtos.ZypherFileDto, error) {
+	hshr, err := zyp.LookupHasher(hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := zyp.NewWriter(zyp.WithHasher(hshr))
+	size, err := io.Copy(writer, rdr)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := writer.Digest()
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.ZypherFileDto{Result: digest, Hasher: hshr.Name(), Size: size}, nil
+}
+
+func CalculateMac(req dtos.ZypherMacRequest) (*dtos.ZypherMacDto, error) {
+	hshr, err := zyp.LookupHasher(req.Hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := zyp.NewZypher(zyp.WithHasher(hshr)).MAC([]byte(req.Key), req.Message)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.ZypherMacDto{Mac: mac, Hasher: hshr.Name()}, nil
+}
+
+func DeriveKey(req dtos.ZypherDeriveRequest) (*dtos.ZypherDeriveDto, error) {
+	hshr, err := zyp.LookupHasher(req.Hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := zyp.NewZypher(zyp.WithHasher(hshr)).DeriveKey(req.Secret, []byte(req.Salt), req.Info, req.Length)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.ZypherDeriveDto{Key: hex.EncodeToString(key), Length: len(key), Hasher: hshr.Name()}, nil
+}
+
+// EstimateStrength rates a password and prices its crack time in zyphs built from ops, measuring the price
+// runs real zyphs so it takes a worker and is held to the same budget as a zyph request
+func EstimateStrength(ctx context.Context, req dtos.ZypherStrengthRequest, ops ...func(*zyp.Zypher)) (*zyp.Strength, error) {
+	zypher := zyp.NewZypher(ops...)
+	var strength *zyp.Strength
+	err := onWorker(ctx, zypher, new(atomic.Int64), func() (err error) {
+		strength, err = zypher.EstimateStrength(req.Password)
+		return err
+	})
+	return strength, err
+}
+
+// visitors get an id on their first page view, zysettings.hashCount may be calibrated for storing passwords
+// and take seconds so the ids get at most this many rounds, a few milliseconds of sha512
+const maxVisitorHashCount = 1 << 12
+
+// VisitorHashCount is the hash count for visitor ids
+func VisitorHashCount(count int) int {
+	return min(count, maxVisitorHashCount)
+}
+
+// VisitorHasher picks the hasher for visitor ids, they have to come out the same on every visit
+// so bcrypt is swapped for the default digest, and so are the kdfs which are too slow for a page view
+func VisitorHasher(name string) string {
+	if hshr, err := zyp.LookupHasher(name); err == nil && hshr.Iterative() {
+		return hshr.Name()
+	}
+	return zyp.SHA512
 }
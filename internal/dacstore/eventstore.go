@@ -0,0 +1,125 @@
+package dacstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const eventSeqKey = "wsman:event-seq"
+const eventLogKey = "wsman:event-log"
+const scheduleVersionPrefix = "wsman:schedule-version:"
+
+// NextEventSeq increments and returns the hub wide broadcast sequence number
+func NextEventSeq(ctx context.Context, client *redis.Client) (int64, error) {
+	seq, err := client.Incr(ctx, eventSeqKey).Result()
+	if err != nil {
+		loggr.MustDebug(fmt.Sprintf("error incrementing event sequence:: %v", err))
+		return 0, fmt.Errorf("error incrementing event sequence:: %w", err)
+	}
+	return seq, nil
+}
+
+// CurrentEventSeq returns the last sequence number handed out, zero if nothing has been broadcast yet
+func CurrentEventSeq(ctx context.Context, client *redis.Client) (int64, error) {
+	val, err := client.Get(ctx, eventSeqKey).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error reading event sequence:: %w", err)
+	}
+
+	seq, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid event sequence value %v:: %w", val, err)
+	}
+	return seq, nil
+}
+
+// AppendEventLog stores a broadcast under its sequence number and trims the log down to maxLen entries
+func AppendEventLog(ctx context.Context, client *redis.Client, seq int64, data []byte, maxLen int64) error {
+	pipe := client.TxPipeline()
+	pipe.ZAdd(ctx, eventLogKey, redis.Z{Score: float64(seq), Member: data})
+	// ranks are ascending so removing from the start keeps the newest maxLen events
+	pipe.ZRemRangeByRank(ctx, eventLogKey, 0, -(maxLen + 1))
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		loggr.MustDebug(fmt.Sprintf("error appending event %v to log:: %v", seq, err))
+		return fmt.Errorf("error appending event %v to log:: %w", seq, err)
+	}
+	return nil
+}
+
+// OldestEventSeq returns the sequence number of the oldest event still held in the log, zero if the log is empty
+func OldestEventSeq(ctx context.Context, client *redis.Client) (int64, error) {
+	oldest, err := client.ZRangeWithScores(ctx, eventLogKey, 0, 0).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error reading oldest logged event:: %w", err)
+	}
+
+	if len(oldest) == 0 {
+		return 0, nil
+	}
+	return int64(oldest[0].Score), nil
+}
+
+// FetchEventsSince returns every logged event with a sequence number greater than seq, oldest first
+func FetchEventsSince(ctx context.Context, client *redis.Client, seq int64) ([]string, error) {
+	events, err := client.ZRangeByScore(ctx, eventLogKey, &redis.ZRangeBy{
+		Min: fmt.Sprintf("(%d", seq),
+		Max: "+inf",
+	}).Result()
+
+	if err != nil {
+		loggr.MustDebug(fmt.Sprintf("error reading events since %v:: %v", seq, err))
+		return nil, fmt.Errorf("error reading events since %v:: %w", seq, err)
+	}
+	return events, nil
+}
+
+// NextScheduleVersion bumps the version of the schedule for a day, every task change broadcast for that day gets the next version
+func NextScheduleVersion(ctx context.Context, client *redis.Client, day string) (int64, error) {
+	version, err := client.Incr(ctx, scheduleVersionPrefix+day).Result()
+	if err != nil {
+		loggr.MustDebug(fmt.Sprintf("error incrementing schedule version for %v:: %v", day, err))
+		return 0, fmt.Errorf("error incrementing schedule version for %v:: %w", day, err)
+	}
+	return version, nil
+}
+
+// CurrentScheduleVersions returns the version of each day, days that never changed are at 0
+func CurrentScheduleVersions(ctx context.Context, client *redis.Client, days []string) (map[string]int64, error) {
+	versions := make(map[string]int64, len(days))
+	if len(days) == 0 {
+		return versions, nil
+	}
+
+	keys := make([]string, len(days))
+	for i, day := range days {
+		keys[i] = scheduleVersionPrefix + day
+	}
+
+	vals, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		loggr.MustDebug(fmt.Sprintf("error reading schedule versions:: %v", err))
+		return nil, fmt.Errorf("error reading schedule versions:: %w", err)
+	}
+
+	for i, val := range vals {
+		versions[days[i]] = 0
+		raw, ok := val.(string)
+		if !ok {
+			continue
+		}
+
+		version, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule version value %v:: %w", raw, err)
+		}
+		versions[days[i]] = version
+	}
+	return versions, nil
+}
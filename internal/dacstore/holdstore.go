@@ -0,0 +1,128 @@
+package dacstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// every hold is a field of this hash keyed by its holder, so reading them all is one HGETALL rather than a keyspace scan
+const slotHoldsKey = "slot-holds"
+const slotHoldLockKey = "slot-hold-lock"
+
+// only deletes the lock when it is still owned by token so an expired lock taken over by another caller is left alone
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// AcquireSlotHoldLock tries once to take the lock guarding hold and booking checks, the lock expires after ttl if never released
+func AcquireSlotHoldLock(ctx context.Context, client *redis.Client, token string, ttl time.Duration) (bool, error) {
+	ok, err := client.SetNX(ctx, slotHoldLockKey, token, ttl).Result()
+	if err != nil {
+		loggr.MustDebug(fmt.Sprintf("error acquiring slot hold lock:: %v", err))
+		return false, fmt.Errorf("error acquiring slot hold lock:: %w", err)
+	}
+	return ok, nil
+}
+
+func ReleaseSlotHoldLock(ctx context.Context, client *redis.Client, token string) error {
+	if err := releaseLockScript.Run(ctx, client, []string{slotHoldLockKey}, token).Err(); err != nil && err != redis.Nil {
+		loggr.MustDebug(fmt.Sprintf("error releasing slot hold lock:: %v", err))
+		return fmt.Errorf("error releasing slot hold lock:: %w", err)
+	}
+	return nil
+}
+
+// SetSlotHold stores a hold under its holder, a holder only ever has one hold so a new hold replaces the old one.
+// It returns the replaced hold when there was one that had not expired yet. Every hold lasts ttl so the whole hash
+// expires with the newest one
+func SetSlotHold(ctx context.Context, client *redis.Client, hold models.SlotHold, ttl time.Duration) (*models.SlotHold, error) {
+	data, err := json.Marshal(hold)
+	if err != nil {
+		return nil, utils.NewJsonEncodeErr(hold, err)
+	}
+
+	var previous *redis.StringCmd
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		previous = pipe.HGet(ctx, slotHoldsKey, hold.Holder)
+		pipe.HSet(ctx, slotHoldsKey, hold.Holder, data)
+		pipe.Expire(ctx, slotHoldsKey, ttl)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		loggr.MustDebug(fmt.Sprintf("error caching slot hold:: %v", err))
+		return nil, fmt.Errorf("error caching slot hold:: %w", err)
+	}
+	return liveHold(previous), nil
+}
+
+// RemoveSlotHold drops the hold of holder and returns it, nil when the holder had no hold or it had expired
+func RemoveSlotHold(ctx context.Context, client *redis.Client, holder string) (*models.SlotHold, error) {
+	var removed *redis.StringCmd
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.HGet(ctx, slotHoldsKey, holder)
+		pipe.HDel(ctx, slotHoldsKey, holder)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		loggr.MustDebug(fmt.Sprintf("error removing slot hold for %v:: %v", holder, err))
+		return nil, fmt.Errorf("error removing slot hold for %v:: %w", holder, err)
+	}
+	return liveHold(removed), nil
+}
+
+// FetchSlotHolds returns every hold that has not expired yet, the expired ones are dropped from the hash on the way
+func FetchSlotHolds(ctx context.Context, client *redis.Client) ([]models.SlotHold, error) {
+	vals, err := client.HGetAll(ctx, slotHoldsKey).Result()
+	if err != nil {
+		loggr.MustDebug(fmt.Sprintf("error reading slot holds:: %v", err))
+		return nil, fmt.Errorf("error reading slot holds:: %w", err)
+	}
+
+	holds := make([]models.SlotHold, 0, len(vals))
+	expired := make([]string, 0)
+	for holder, raw := range vals {
+		var hold models.SlotHold
+		if err := json.Unmarshal([]byte(raw), &hold); err != nil {
+			loggr.MustDebug(fmt.Sprintf("error unmarshalling slot hold: %v", err))
+			expired = append(expired, holder)
+			continue
+		}
+
+		if hold.Expired() {
+			expired = append(expired, holder)
+			continue
+		}
+		holds = append(holds, hold)
+	}
+
+	if len(expired) > 0 {
+		if err := client.HDel(ctx, slotHoldsKey, expired...).Err(); err != nil {
+			// they are skipped on every read until this works
+			loggr.MustDebug(fmt.Sprintf("error removing expired slot holds:: %v", err))
+		}
+	}
+	return holds, nil
+}
+
+// liveHold decodes the hold read by cmd, nil when there was none or it had expired
+func liveHold(cmd *redis.StringCmd) *models.SlotHold {
+	raw, err := cmd.Result()
+	if err != nil {
+		return nil
+	}
+
+	var hold models.SlotHold
+	if err := json.Unmarshal([]byte(raw), &hold); err != nil || hold.Expired() {
+		return nil
+	}
+	return &hold
+}
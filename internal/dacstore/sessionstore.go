@@ -0,0 +1,71 @@
+package dacstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+const sessionPrefix = "session:"
+
+func SetSession(ctx context.Context, client *redis.Client, token string, sess models.Session, ttl time.Duration) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return utils.NewJsonEncodeErr(sess, err)
+	}
+
+	if err = client.Set(ctx, sessionPrefix+token, data, ttl).Err(); err != nil {
+		loggr.MustDebug(fmt.Sprintf("error caching session:: %v", err))
+		return fmt.Errorf("error caching session:: %w", err)
+	}
+	return nil
+}
+
+// FetchSession resolves a token to its session, unknown and expired tokens return an ErrNoCacheResult
+func FetchSession(ctx context.Context, client *redis.Client, token string) (*models.Session, error) {
+	// the token is a secret so only the prefix is used in errors and logs
+	val, err := client.Get(ctx, sessionPrefix+token).Result()
+	if err != nil {
+		if err != redis.Nil {
+			loggr.MustDebug(fmt.Sprintf("unexpected session cache error:: %v", err))
+			return nil, fmt.Errorf("unexpected session cache error:: %w", err)
+		}
+		return nil, NewNoCacheResultErr(client.ClientID(ctx), sessionPrefix, err)
+	}
+
+	var sess models.Session
+	if err = json.Unmarshal([]byte(val), &sess); err != nil {
+		loggr.MustDebug(fmt.Sprintf("error unmarshalling session:: %v", err))
+		return nil, fmt.Errorf("unexpected session cache error:: %w", err)
+	}
+	return &sess, nil
+}
+
+const ownerTicketPrefix = "owner_ticket:"
+
+// SetOwnerTicket stores a ticket that lets the owner open one websocket without sending the admin key
+func SetOwnerTicket(ctx context.Context, client *redis.Client, ticket string, ttl time.Duration) error {
+	if err := client.Set(ctx, ownerTicketPrefix+ticket, time.Now().UTC().Add(ttl).Format(time.RFC3339), ttl).Err(); err != nil {
+		loggr.MustDebug(fmt.Sprintf("error caching owner ticket:: %v", err))
+		return fmt.Errorf("error caching owner ticket:: %w", err)
+	}
+	return nil
+}
+
+// RedeemOwnerTicket deletes the ticket as it reads it so each ticket opens one connection, unknown, used and expired
+// tickets return an ErrNoCacheResult
+func RedeemOwnerTicket(ctx context.Context, client *redis.Client, ticket string) error {
+	if err := client.GetDel(ctx, ownerTicketPrefix+ticket).Err(); err != nil {
+		if err != redis.Nil {
+			loggr.MustDebug(fmt.Sprintf("unexpected owner ticket cache error:: %v", err))
+			return fmt.Errorf("unexpected owner ticket cache error:: %w", err)
+		}
+		return NewNoCacheResultErr(client.ClientID(ctx), ownerTicketPrefix, err)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Z3DRP/zportfolio-service/enums"
 	"github.com/Z3DRP/zportfolio-service/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -64,6 +65,29 @@ func (t TaskStore) FetchTaskInPeriod(ctx context.Context, start, end time.Time)
 	return tasks, nil
 }
 
+// FetchOverlappingTasks returns the tasks sharing any time with start to end, a declined task frees its slot so it is left out
+func (t TaskStore) FetchOverlappingTasks(ctx context.Context, start, end time.Time) (models.Tasklist, error) {
+	var tasks models.Tasklist
+	filter := bson.M{
+		"start_time": bson.M{"$lt": end},
+		"end_time":   bson.M{"$gt": start},
+		"status":     bson.M{"$ne": enums.Declined},
+	}
+
+	cur, err := t.collection.Find(ctx, filter)
+	if err != nil {
+		logger.MustDebug(fmt.Sprintf("error occurred while fetching overlapping tasks:: %s", err))
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	if err := cur.All(ctx, &tasks); err != nil {
+		logger.MustDebug(fmt.Sprintf("error decoding overlapping tasks:: %v", err))
+		return nil, err
+	}
+	return tasks, nil
+}
+
 func (t TaskStore) FetchTask(ctx context.Context, tid string) (models.Modler, error) {
 	var task models.Task
 	filter := bson.M{"tid": tid}
@@ -96,6 +120,21 @@ func (t TaskStore) UpdateTask(ctx context.Context, tid string, tsk *models.Task)
 	return result.MatchedCount, result.ModifiedCount, nil
 }
 
+func (t TaskStore) UpdateTaskStatus(ctx context.Context, tid string, status enums.TaskStatus) (int64, int64, error) {
+	filter := bson.M{"tid": tid}
+	update := bson.D{{
+		Key:   "$set",
+		Value: bson.M{"status": status},
+	}}
+
+	result, err := t.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return result.MatchedCount, result.ModifiedCount, nil
+}
+
 func (t TaskStore) DeleteTask(ctx context.Context, tid string) (int64, error) {
 	filter := bson.M{"tid": tid}
 	result, err := t.collection.DeleteOne(ctx, filter)
@@ -7,6 +7,7 @@ import (
 	"github.com/Z3DRP/zportfolio-service/enums"
 	adp "github.com/Z3DRP/zportfolio-service/internal/adapters"
 	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/zypher"
 )
 
 type DTOer interface {
@@ -150,6 +151,8 @@ type ScheduleDto struct {
 	DaysAvailable  map[int]bool
 	HoursAvailable map[string]bool // keys are composites of weekday and hour
 	CurrentPeriod  PeriodDto
+	Pending        []models.PendingSlot
+	Versions       map[string]int64 // schedule version of each utc day in the period, a task delta continues from its day's version
 }
 
 func initScheduleDto(sch models.Schedule, p models.Period) ScheduleDto {
@@ -169,7 +172,32 @@ func initScheduleDto(sch models.Schedule, p models.Period) ScheduleDto {
 }
 
 func NewScheduleDto(s *models.ScheduleResponse) ScheduleDto {
-	return initScheduleDto(*s.Agenda, s.CurrentPeriod)
+	dto := initScheduleDto(*s.Agenda, s.CurrentPeriod)
+	dto.Pending = s.Pending
+	dto.Versions = s.Versions
+	return dto
+}
+
+// TaskDeltaDto carries a single changed task and the grid cells it lands in so clients can patch their schedule in place,
+// Version is the version of Day, the utc day the task starts on
+type TaskDeltaDto struct {
+	Day     string
+	Version int64
+	Task    models.Task
+	DayKey  int
+	HourKey int
+	CellKey string
+}
+
+func NewTaskDeltaDto(day string, version int64, tsk models.Task) TaskDeltaDto {
+	return TaskDeltaDto{
+		Day:     day,
+		Version: version,
+		Task:    tsk,
+		DayKey:  tsk.WeekDay(),
+		HourKey: tsk.TaskHrKey(),
+		CellKey: tsk.TaskCellKey(),
+	}
 }
 
 type AvailabilityDto struct {
@@ -283,3 +311,184 @@ func NewEventDto[P Payloader](etype string, payload P) EventDto[P] {
 func StringifyDto[P Payloader](pload P) string {
 	return fmt.Sprintf("%#v\n", pload)
 }
+
+type PeriodPresenceDto struct {
+	PeriodStart string
+	PeriodEnd   string
+	Viewers     int
+}
+
+type PresenceDto struct {
+	Periods []PeriodPresenceDto
+	Total   int
+}
+
+type ConnectionDto struct {
+	Id          string
+	Owner       bool
+	PeriodStart string
+	PeriodEnd   string
+	ConnectedAt time.Time
+}
+
+// TaskNoticeDto tells the owner what happened to a task, Visitor is only filled in for new requests
+type TaskNoticeDto struct {
+	Action  string
+	Status  string
+	Task    models.Task
+	Visitor *adp.UserData
+}
+
+func NewTaskNoticeDto(action string, tsk models.Task, visitor *adp.UserData) TaskNoticeDto {
+	return TaskNoticeDto{
+		Action:  action,
+		Status:  tsk.Status.String(),
+		Task:    tsk,
+		Visitor: visitor,
+	}
+}
+
+type EmailFailureDto struct {
+	Tid       string
+	Kind      string
+	Recipient string
+	Error     string
+}
+
+type ZypherStepDto struct {
+	Stage     string
+	Iteration int
+	Output    string
+	Duration  string
+}
+
+// ZypherExplainDto walks through every step of a zyph, Params are the settings that were actually used
+type ZypherExplainDto struct {
+	Result         string
+	Mode           string
+	Params         zypher.Params
+	LosslessSpace  bool
+	Steps          []ZypherStepDto
+	StageDurations map[string]string
+	Duration       string
+}
+
+func NewZypherExplainDto(result, mode string, zy zypher.Zypher, trace *zypher.Trace, elapsed time.Duration) ZypherExplainDto {
+	steps := make([]ZypherStepDto, 0, len(trace.Steps))
+	for _, step := range trace.Steps {
+		steps = append(steps, ZypherStepDto{
+			Stage:     step.Stage,
+			Iteration: step.Iteration,
+			Output:    step.Output,
+			Duration:  step.Duration.String(),
+		})
+	}
+
+	return ZypherExplainDto{
+		Result:        result,
+		Mode:          mode,
+		Params:        zy.Params(),
+		LosslessSpace: zy.LosslessSpace,
+		Steps:         steps,
+		StageDurations: map[string]string{
+			zypher.StageShift: trace.StageDuration(zypher.StageShift).String(),
+			zypher.StageHash:  trace.StageDuration(zypher.StageHash).String(),
+		},
+		Duration: elapsed.String(),
+	}
+}
+
+type ZypherFileDto struct {
+	Result   string
+	Hasher   string
+	Filename string
+	Size     int64
+}
+
+type ZypherMacRequest struct {
+	Key     string
+	Message string
+	Hasher  string
+}
+
+type ZypherMacDto struct {
+	Mac    string
+	Hasher string
+}
+
+// ZypherDeriveRequest takes the salt as text, the derived key is sent back hex encoded
+type ZypherDeriveRequest struct {
+	Secret string
+	Salt   string
+	Info   string
+	Length int
+	Hasher string
+}
+
+type ZypherDeriveDto struct {
+	Key    string
+	Length int
+	Hasher string
+}
+
+// ZypherRequest is the body of POST /zypher, Txt is a single input and Inputs a batch.
+// Counts left out keep the zypher defaults
+type ZypherRequest struct {
+	Txt          string
+	Inputs       []string
+	Shift        *int
+	ShiftCount   *int
+	HashCount    *int
+	Alternate    bool
+	IgnoreSpace  bool
+	RestrictHash bool
+	Lossless     bool
+	Hasher       string
+	Mode         string
+	Explain      bool
+}
+
+type ZypherItemDto struct {
+	Result string
+	Error  string
+}
+
+func NewZypherItemDto(result string, err error) ZypherItemDto {
+	if err != nil {
+		return ZypherItemDto{Error: err.Error()}
+	}
+	return ZypherItemDto{Result: result}
+}
+
+type ZypherBatchDto struct {
+	Results []ZypherItemDto
+	Hasher  string
+	Mode    string
+}
+
+// ZypherAnalysisRequest is the body of POST /zypher/analysis, the zypher options match ZypherRequest
+type ZypherAnalysisRequest struct {
+	Shift        *int
+	ShiftCount   *int
+	HashCount    *int
+	Alternate    bool
+	IgnoreSpace  bool
+	RestrictHash bool
+	Hasher       string
+	Corpus       string
+	Size         int
+	Seed         int64
+}
+
+type ZypherAnalysisJobDto struct {
+	Id       string
+	Status   string
+	Error    string
+	Created  time.Time
+	Finished time.Time
+	Result   *zypher.Analysis
+}
+
+type ZypherStrengthRequest struct {
+	Password string
+}
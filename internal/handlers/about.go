@@ -12,6 +12,7 @@ import (
 	"github.com/Z3DRP/zportfolio-service/internal/controller"
 	"github.com/Z3DRP/zportfolio-service/internal/dacstore"
 	"github.com/Z3DRP/zportfolio-service/internal/dtos"
+	"github.com/Z3DRP/zportfolio-service/internal/middleware"
 	"github.com/Z3DRP/zportfolio-service/internal/models"
 	"github.com/Z3DRP/zportfolio-service/internal/utils"
 	zlg "github.com/Z3DRP/zportfolio-service/internal/zlogger"
@@ -31,7 +32,7 @@ func GetAbout(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	select {
 	case <-r.Context().Done():
-		logger.MustDebug(fmt.Sprintf("request: %s method: %s timed out", r.URL, r.Method))
+		logger.MustDebug(fmt.Sprintf("request: %s method: %s timed out", middleware.RedactedURI(r), r.Method))
 		http.Error(w, "request time out", http.StatusRequestTimeout)
 		return
 	default:
@@ -147,7 +148,7 @@ func updateVisitorCount(settings config.ZypherConfig, cacheClient *redis.Client,
 	}
 
 	if usrDto.Uid == "" {
-		nwUid, err := controller.CalculateZypher(uip, settings.Shift, settings.ShiftCount, settings.HashCount, settings.Alternate, settings.IgnSpace, settings.RestrictHash)
+		nwUid, _, err := controller.CalculateZypherWithHasher(uip, settings.Shift, settings.ShiftCount, controller.VisitorHashCount(settings.HashCount), settings.Alternate, settings.IgnSpace, settings.RestrictHash, controller.VisitorHasher(settings.Hasher))
 		// add user to cache so when trying to edit tasks id can be checked
 		if err != nil {
 			logDebug(logger, err)
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Z3DRP/zportfolio-service/internal/middleware"
+	"github.com/Z3DRP/zportfolio-service/internal/wsman"
+)
+
+func GetConnections(w http.ResponseWriter, r *http.Request, manager *wsman.Manager) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		logger.MustDebug(fmt.Sprintf("request: %s method: %s timed out", middleware.RedactedURI(r), r.Method))
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+		return
+	default:
+		response := map[string]interface{}{
+			"presence":    manager.Presence(),
+			"connections": manager.Connections(),
+		}
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.MustDebug(fmt.Sprintf("could not encode connections response: %s", err))
+			http.Error(w, "could not encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetEvents documents the websocket events the manager currently handles
+func GetEvents(w http.ResponseWriter, r *http.Request, manager *wsman.Manager) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		logger.MustDebug(fmt.Sprintf("request: %s method: %s timed out", middleware.RedactedURI(r), r.Method))
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+		return
+	default:
+		response := map[string]interface{}{
+			"events": manager.Describe(),
+		}
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.MustDebug(fmt.Sprintf("could not encode events response: %s", err))
+			http.Error(w, "could not encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
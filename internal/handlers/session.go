@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/dacstore"
+	"github.com/Z3DRP/zportfolio-service/internal/middleware"
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+	"github.com/Z3DRP/zportfolio-service/internal/wsman"
+)
+
+// CreateSession hands out the token the schedule websocket uses to tie tasks to a visitor, a still valid session is reused
+func CreateSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		logger.MustDebug(fmt.Sprintf("request: %s method: %s timed out", middleware.RedactedURI(r), r.Method))
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+		return
+	default:
+		cacheClient, err := dacstore.NewRedisClient(r.Context())
+		if err != nil {
+			logger.MustDebug(dacstore.NewRedisConnErr(cacheClient.ClientID(r.Context()), err).Error())
+			http.Error(w, "could not connect to session store", http.StatusInternalServerError)
+			return
+		}
+
+		token := wsman.SessionToken(r)
+		var sess *models.Session
+		if token != "" {
+			sess, err = dacstore.FetchSession(r.Context(), cacheClient, token)
+			var noResults *dacstore.ErrNoCacheResult
+			if err != nil && !errors.As(err, &noResults) {
+				logger.MustDebug(utils.NewCacheOpErr("reading", "session", err).Error())
+				http.Error(w, "could not read session", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if sess == nil {
+			rawToken, err := utils.GenToken()
+			if err != nil {
+				logger.MustDebug(utils.NewIdGenErr("session token", err).Error())
+				http.Error(w, "could not create session", http.StatusInternalServerError)
+				return
+			}
+			token = hex.EncodeToString(rawToken)
+
+			uid, err := utils.GenerateID("user")
+			if err != nil {
+				logger.MustDebug(utils.NewIdGenErr("user", err).Error())
+				http.Error(w, "could not create session", http.StatusInternalServerError)
+				return
+			}
+			sess = models.NewSession(uid, wsman.SessionTTL)
+		} else {
+			// reusing a session slides its expiry so returning visitors keep their tasks
+			sess.ExpiresAt = time.Now().UTC().Add(wsman.SessionTTL)
+		}
+
+		if err = dacstore.SetSession(r.Context(), cacheClient, token, *sess, wsman.SessionTTL); err != nil {
+			logger.MustDebug(utils.NewCacheOpErr("writing", "session", err).Error())
+			http.Error(w, "could not save session", http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     wsman.SessionCookieName,
+			Value:    token,
+			Path:     "/",
+			Expires:  sess.ExpiresAt,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		// the token is returned as well for clients on another origin that have to offer it as a subprotocol
+		response := map[string]interface{}{
+			"token":     token,
+			"uid":       sess.Uid,
+			"expiresAt": sess.ExpiresAt,
+		}
+
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logger.MustDebug(fmt.Sprintf("could not encode session response: %s", err))
+			http.Error(w, "could not encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// CreateOwnerTicket hands the owner a short lived ticket for the owner websocket, browsers cannot send the admin key on an upgrade
+func CreateOwnerTicket(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		logger.MustDebug(fmt.Sprintf("request: %s method: %s timed out", middleware.RedactedURI(r), r.Method))
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+		return
+	default:
+		cacheClient, err := dacstore.NewRedisClient(r.Context())
+		if err != nil {
+			logger.MustDebug(dacstore.NewRedisConnErr(cacheClient.ClientID(r.Context()), err).Error())
+			http.Error(w, "could not connect to session store", http.StatusInternalServerError)
+			return
+		}
+
+		rawTicket, err := utils.GenToken()
+		if err != nil {
+			logger.MustDebug(utils.NewIdGenErr("owner ticket", err).Error())
+			http.Error(w, "could not create owner ticket", http.StatusInternalServerError)
+			return
+		}
+		ticket := hex.EncodeToString(rawTicket)
+
+		if err = dacstore.SetOwnerTicket(r.Context(), cacheClient, ticket, wsman.OwnerTicketTTL); err != nil {
+			logger.MustDebug(utils.NewCacheOpErr("writing", "owner ticket", err).Error())
+			http.Error(w, "could not save owner ticket", http.StatusInternalServerError)
+			return
+		}
+
+		expiresAt := time.Now().UTC().Add(wsman.OwnerTicketTTL)
+		http.SetCookie(w, &http.Cookie{
+			Name:     wsman.OwnerCookieName,
+			Value:    ticket,
+			Path:     "/admin/ws",
+			Expires:  expiresAt,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		// pages on another origin offer the ticket as a subprotocol since the cookie is not sent to them
+		response := map[string]interface{}{
+			"ticket":      ticket,
+			"subprotocol": wsman.OwnerTicketProtocol + ticket,
+			"expiresAt":   expiresAt,
+		}
+
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logger.MustDebug(fmt.Sprintf("could not encode owner ticket response: %s", err))
+			http.Error(w, "could not encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -1,13 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strconv"
+	"strings"
 
+	"github.com/Z3DRP/zportfolio-service/config"
 	"github.com/Z3DRP/zportfolio-service/internal/controller"
+	"github.com/Z3DRP/zportfolio-service/internal/dtos"
 	"github.com/Z3DRP/zportfolio-service/internal/zlogger"
+	"github.com/Z3DRP/zportfolio-service/internal/zypher"
 )
 
 func GetZypher(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
@@ -17,65 +26,467 @@ func GetZypher(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
 	case <-r.Context().Done():
 		http.Error(w, "request time out", http.StatusRequestTimeout)
 	default:
-		txt := r.URL.Query().Get("txt")
-		shift := r.URL.Query().Get("shft")
-		shiftCount := r.URL.Query().Get("shftcount")
-		hashCount := r.URL.Query().Get("hshcount")
-		alternate := r.URL.Query().Get("alt")
-		ignoreSpace := r.URL.Query().Get("ignspace")
-		restrictHashShift := r.URL.Query().Get("restricthash")
-
-		shf, err := parseInt(shift)
+		var req dtos.ZypherRequest
+		if r.URL.Query().Has("txt") {
+			settings, err := config.ReadZypherSettings()
+			if err != nil || !settings.QueryInput {
+				logger.MustDebug("query param input sent while disabled")
+				http.Error(w, "query parameter input is disabled, send the input as a json body", http.StatusBadRequest)
+				return
+			}
+
+			req, err = zypherQueryRequest(r.URL.Query())
+			if err != nil {
+				logger.MustDebug(err.Error())
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxZypherRequestSize)).Decode(&req); err != nil {
+			logger.MustDebug(fmt.Sprintf("invalid zypher request: %s", err))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "expected a json body with txt or inputs", http.StatusBadRequest)
+			return
+		} else if r.URL.Query().Has("explain") {
+			// explain is a view of the response rather than a zypher option, so it is also taken from the query next to a body
+			explain, err := parseOptionalBool(r.URL.Query().Get("explain"))
+			if err != nil {
+				http.Error(w, "invalid 'explain' parameter", http.StatusBadRequest)
+				return
+			}
+			req.Explain = req.Explain || explain
+		}
+
+		if req.Inputs != nil {
+			if req.Txt != "" {
+				http.Error(w, "send either txt or inputs, not both", http.StatusBadRequest)
+				return
+			}
+			if req.Explain {
+				http.Error(w, "explain only works with a single txt input", http.StatusBadRequest)
+				return
+			}
+
+			results, err := controller.CalculateZypherBatch(r.Context(), req)
+			if err != nil {
+				writeZypherErr(w, logger, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(results)
+			return
+		}
+
+		if req.Explain {
+			explained, err := controller.ExplainZypher(r.Context(), req)
+			if err != nil {
+				writeZypherErr(w, logger, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(explained)
+			return
+		}
+
+		result, hasherName, err := controller.CalculateZypherRequest(r.Context(), req)
 		if err != nil {
-			logger.MustDebug("invalid shift param")
-			http.Error(w, "invalid 'shift' parameter", http.StatusBadRequest)
+			writeZypherErr(w, logger, err)
+			return
+		}
+
+		response := map[string]string{"result": result}
+		if req.Mode != "" {
+			response["mode"] = req.Mode
+		} else {
+			response["hasher"] = hasherName
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// a full batch needs more room than the single key and message bodies
+const maxZypherRequestSize = 1 << 20
+
+// zypherQueryRequest reads the old query param input, it is only reachable when zysettings.queryInput is on
+func zypherQueryRequest(query url.Values) (dtos.ZypherRequest, error) {
+	req := dtos.ZypherRequest{
+		Txt:    query.Get("txt"),
+		Hasher: query.Get("hasher"),
+		Mode:   query.Get("mode"),
+	}
+
+	shf, err := parseInt(query.Get("shft"))
+	if err != nil {
+		return req, errors.New("invalid 'shift' parameter")
+	}
+	shfCount, err := parseInt(query.Get("shftcount"))
+	if err != nil {
+		return req, errors.New("invalid 'shiftCount' parameter")
+	}
+	hshCount, err := parseInt(query.Get("hshcount"))
+	if err != nil {
+		return req, errors.New("invalid 'hashCount' parameter")
+	}
+	req.Shift, req.ShiftCount, req.HashCount = &shf, &shfCount, &hshCount
+
+	alt, err := parseBool(query.Get("alt"))
+	if err != nil {
+		return req, errors.New("invalid 'alternate' parameter")
+	}
+	ignSpace, err := parseBool(query.Get("ignspace"))
+	if err != nil {
+		return req, errors.New("invalid 'ignoreSpace' parameter")
+	}
+	rstHsh, err := parseBool(query.Get("restricthash"))
+	if err != nil {
+		return req, errors.New("invalid 'restrictHash' parameter")
+	}
+	req.Alternate, req.IgnoreSpace, req.RestrictHash = *alt, *ignSpace, *rstHsh
+
+	if req.Lossless, err = parseOptionalBool(query.Get("lossless")); err != nil {
+		return req, errors.New("invalid 'lossless' parameter")
+	}
+	if req.Explain, err = parseOptionalBool(query.Get("explain")); err != nil {
+		return req, errors.New("invalid 'explain' parameter")
+	}
+	return req, nil
+}
+
+// writeZypherErr maps the errors of a zypher request, bad options and input are the callers fault and the rest are ours
+func writeZypherErr(w http.ResponseWriter, logger zlogger.Zlogrus, err error) {
+	logger.MustDebug(fmt.Sprintf("error occurred while calculating zypher: %s", err))
+	switch {
+	case errors.Is(err, zypher.ErrUnknownHasher):
+		http.Error(w, fmt.Sprintf("invalid 'hasher' parameter, expected one of %s", strings.Join(zypher.HasherNames(), ", ")), http.StatusBadRequest)
+	case errors.Is(err, controller.ErrUnknownShiftMode):
+		http.Error(w, "invalid 'mode' parameter, expected asci or hex", http.StatusBadRequest)
+	case errors.Is(err, zypher.ErrInvalidInput), errors.Is(err, controller.ErrZypherBatchSize), errors.Is(err, controller.ErrKdfPerInput),
+		errors.Is(err, controller.ErrZypherCount), errors.Is(err, controller.ErrZypherBudget):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+	default:
+		http.Error(w, "error occured while calculating zypher", http.StatusInternalServerError)
+	}
+}
+
+// GetUnzyph reads the same json body as /zypher so the text being reversed stays out of the url and request logs
+func GetUnzyph(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+	default:
+		var req dtos.ZypherRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxZypherRequestSize)).Decode(&req); err != nil {
+			logger.MustDebug(fmt.Sprintf("invalid unzyph request: %s", err))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "expected a json body with txt", http.StatusBadRequest)
+			return
+		}
+
+		if req.Inputs != nil || req.Explain {
+			http.Error(w, "unzyph only reverses a single txt input", http.StatusBadRequest)
+			return
+		}
+
+		result, err := controller.CalculateUnzyph(r.Context(), req)
+		if errors.Is(err, zypher.ErrNotReversible) {
+			logger.MustDebug(fmt.Sprintf("could not reverse zypher: %s", err))
+			http.Error(w, "text can not be reversed with these parameters", http.StatusUnprocessableEntity)
 			return
 		}
-		shfCount, err := parseInt(shiftCount)
 		if err != nil {
-			logger.MustDebug("invalid shift count param")
-			http.Error(w, "invalid 'shiftCount' parameter", http.StatusBadRequest)
+			writeZypherErr(w, logger, err)
 			return
 		}
-		hshCount, err := parseInt(hashCount)
+
+		w.WriteHeader(http.StatusOK)
+		response := map[string]string{
+			"result": result,
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// files are streamed through the zypher so this only bounds how long a request can tie up the handler
+const maxZypherFileSize = 50 << 20
+
+func GetZypherFile(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+	default:
+		hasher := r.URL.Query().Get("hasher")
+		r.Body = http.MaxBytesReader(w, r.Body, maxZypherFileSize)
+
+		mr, err := r.MultipartReader()
 		if err != nil {
-			logger.MustDebug("invalid hash count")
-			http.Error(w, "invalid 'hashCount' parameter", http.StatusBadRequest)
+			logger.MustDebug(fmt.Sprintf("invalid multipart request: %s", err))
+			http.Error(w, "expected a multipart form with a 'file' field", http.StatusBadRequest)
+			return
+		}
+
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				http.Error(w, "missing 'file' field", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				logger.MustDebug(fmt.Sprintf("error reading multipart form: %s", err))
+				http.Error(w, "invalid multipart form", http.StatusBadRequest)
+				return
+			}
+
+			if part.FormName() != "file" {
+				continue
+			}
+
+			fingerprint, err := controller.ZypherReader(part, hasher)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("file is larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if errors.Is(err, zypher.ErrUnknownHasher) {
+				logger.MustDebug("invalid hasher param")
+				http.Error(w, fmt.Sprintf("invalid 'hasher' parameter, expected one of %s", strings.Join(zypher.HasherNames(), ", ")), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, zypher.ErrNotDeterministic) || errors.Is(err, zypher.ErrDigestOnly) {
+				logger.MustDebug(fmt.Sprintf("file hasher can not stream: %s", err))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				logger.MustDebug(fmt.Sprintf("error occurred while calculating file zypher: %s", err))
+				http.Error(w, "error occured while calculating hash", http.StatusInternalServerError)
+				return
+			}
+
+			fingerprint.Filename = part.FileName()
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(fingerprint)
 			return
 		}
+	}
+}
 
-		alt, err := parseBool(alternate)
+// keys and messages are sent in the body so they stay out of urls and access logs
+const maxZypherBodySize = 1 << 16
+
+func GetZypherMac(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+	default:
+		var req dtos.ZypherMacRequest
+		if err := decodeKeyedRequest(w, r, &req); err != nil {
+			logger.MustDebug(fmt.Sprintf("invalid mac request: %s", err))
+			http.Error(w, "expected a json body with key and message and no shift options, the message is keyed as is", http.StatusBadRequest)
+			return
+		}
+
+		mac, err := controller.CalculateMac(req)
 		if err != nil {
-			logger.MustDebug("invalid alternate param")
-			http.Error(w, "invalid 'alternate' parameter", http.StatusBadRequest)
+			writeZypherKeyErr(w, logger, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(mac)
+	}
+}
+
+func GetZypherDerive(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+	default:
+		var req dtos.ZypherDeriveRequest
+		if err := decodeKeyedRequest(w, r, &req); err != nil {
+			logger.MustDebug(fmt.Sprintf("invalid derive request: %s", err))
+			http.Error(w, "expected a json body with secret, salt, info and length and no shift options, the secret is keyed as is", http.StatusBadRequest)
 			return
 		}
 
-		ignSpace, err := parseBool(ignoreSpace)
+		key, err := controller.DeriveKey(req)
 		if err != nil {
-			logger.MustDebug("invalid ignore space param")
-			http.Error(w, "invalid 'ignoreSpace' parameter", http.StatusBadRequest)
+			writeZypherKeyErr(w, logger, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(key)
+	}
+}
+
+// StartZypherAnalysis queues a collision and avalanche analysis and answers with the job to poll
+func StartZypherAnalysis(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+	default:
+		var req dtos.ZypherAnalysisRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxZypherBodySize)).Decode(&req); err != nil {
+			logger.MustDebug(fmt.Sprintf("invalid analysis request: %s", err))
+			http.Error(w, "expected a json body with the zypher options, corpus and size", http.StatusBadRequest)
 			return
 		}
 
-		rstHsh, err := parseBool(restrictHashShift)
+		job, err := controller.StartAnalysis(req)
 		if err != nil {
-			logger.MustDebug("invalid restrict hash param")
-			http.Error(w, "invalid 'restrictHash' parameter", http.StatusBadRequest)
+			logger.MustDebug(fmt.Sprintf("error occurred while starting analysis: %s", err))
+			switch {
+			case errors.Is(err, zypher.ErrUnknownHasher):
+				http.Error(w, fmt.Sprintf("invalid 'hasher', expected one of %s", strings.Join(zypher.HasherNames(), ", ")), http.StatusBadRequest)
+			case errors.Is(err, zypher.ErrUnknownCorpus), errors.Is(err, zypher.ErrAnalysisSize), errors.Is(err, controller.ErrKdfPerInput):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, controller.ErrAnalysisBusy):
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+			default:
+				http.Error(w, "error occured while starting analysis", http.StatusInternalServerError)
+			}
 			return
 		}
-		result, err := controller.CalculateZypher(txt, shf, shfCount, hshCount, *alt, *ignSpace, *rstHsh)
+
+		w.Header().Set("Location", "/zypher/analysis/"+job.Id)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+func GetZypherAnalysis(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+	default:
+		job, err := controller.FetchAnalysis(r.PathValue("id"))
 		if err != nil {
-			logger.MustDebug(fmt.Sprintf("error occurred while calculating zypher: %s", err))
-			http.Error(w, "error occured while calculating hash", http.StatusInternalServerError)
+			logger.MustDebug(fmt.Sprintf("error occurred while fetching analysis: %s", err))
+			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
 
 		w.WriteHeader(http.StatusOK)
-		response := map[string]string{
-			"result": result,
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// GetZypherStrength estimates how long the configured zypher would hold up against an offline attack on the password
+func GetZypherStrength(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
+	w.Header().Set("Content-Type", "application/json")
+	select {
+	case <-r.Context().Done():
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+	default:
+		var req dtos.ZypherStrengthRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxZypherBodySize)).Decode(&req); err != nil {
+			logger.MustDebug(fmt.Sprintf("invalid strength request: %s", err))
+			http.Error(w, "expected a json body with password", http.StatusBadRequest)
+			return
 		}
-		json.NewEncoder(w).Encode(response)
+
+		var ops []func(*zypher.Zypher)
+		settings, err := config.ReadZypherSettings()
+		if err == nil {
+			ops, err = zypher.ConfigOps(settings)
+		}
+		if err != nil {
+			logger.MustDebug(fmt.Sprintf("could not read zysettings, estimating with the zypher defaults: %s", err))
+			ops = nil
+		}
+
+		strength, err := controller.EstimateStrength(r.Context(), req, ops...)
+		if errors.Is(err, zypher.ErrEmptyPassword) || errors.Is(err, zypher.ErrPasswordLength) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, controller.ErrZypherBudget) {
+			// the configured zyph is slower than a request may run, nothing the caller sent can change that
+			logger.MustDebug(fmt.Sprintf("could not price a guess within the zypher budget: %s", err))
+			http.Error(w, "the configured zypher is too slow to price a guess", http.StatusServiceUnavailable)
+			return
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, "request time out", http.StatusRequestTimeout)
+			return
+		}
+		if err != nil {
+			logger.MustDebug(fmt.Sprintf("error occurred while estimating strength: %s", err))
+			http.Error(w, "error occured while estimating strength", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(strength)
+	}
+}
+
+// the dockerfile builds cmd/zypher-wasm into this dir next to the go loader and zypher.js
+const zypherWasmDir = "web/zypher"
+
+// content types are set here since the header middleware marks every response as json,
+// and browsers only stream compile wasm served as application/wasm
+var zypherWasmAssets = map[string]string{
+	"zypher.wasm":  "application/wasm",
+	"wasm_exec.js": "text/javascript; charset=utf-8",
+	"zypher.js":    "text/javascript; charset=utf-8",
+}
+
+func GetZypherWasm(w http.ResponseWriter, r *http.Request, logger zlogger.Zlogrus) {
+	select {
+	case <-r.Context().Done():
+		http.Error(w, "request time out", http.StatusRequestTimeout)
+	default:
+		asset := r.PathValue("asset")
+		contentType, ok := zypherWasmAssets[asset]
+		if !ok {
+			logger.MustDebug(fmt.Sprintf("unknown wasm asset requested: %s", asset))
+			http.Error(w, "asset not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		http.ServeFile(w, r, filepath.Join(zypherWasmDir, asset))
+	}
+}
+
+// decodeKeyedRequest reads a mac or derive body. The shift stage is never run on keyed input, so shift options
+// or any other field the request does not have are refused rather than dropped without a word
+func decodeKeyedRequest(w http.ResponseWriter, r *http.Request, req any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxZypherBodySize))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(req)
+}
+
+// writeZypherKeyErr maps the mac and derive errors caused by the request to a 400, anything else is ours
+func writeZypherKeyErr(w http.ResponseWriter, logger zlogger.Zlogrus, err error) {
+	logger.MustDebug(fmt.Sprintf("error occurred while keying zypher: %s", err))
+	switch {
+	case errors.Is(err, zypher.ErrUnknownHasher):
+		http.Error(w, fmt.Sprintf("invalid 'hasher', expected one of %s", strings.Join(zypher.HasherNames(), ", ")), http.StatusBadRequest)
+	case errors.Is(err, zypher.ErrMissingKey), errors.Is(err, zypher.ErrNotDeterministic), errors.Is(err, zypher.ErrDigestOnly),
+		errors.Is(err, zypher.ErrDeriveLength):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "error occured while calculating hash", http.StatusInternalServerError)
 	}
 }
 
@@ -94,3 +505,11 @@ func parseBool(param string) (*bool, error) {
 	}
 	return &arg, nil
 }
+
+// parseOptionalBool treats a missing param as false
+func parseOptionalBool(param string) (bool, error) {
+	if param == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(param)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Z3DRP/zportfolio-service/internal/dtos"
+	"github.com/Z3DRP/zportfolio-service/internal/zlogger"
+)
+
+var testLogger = zlogger.NewLogger(zlogger.NewLogFile(zlogger.WithFilename(os.TempDir() + "/zportfolio-handlers-test.log")))
+
+func serve(handler func(http.ResponseWriter, *http.Request, zlogger.Zlogrus), method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(method, target, strings.NewReader(body)), *testLogger)
+	return rec
+}
+
+func TestKeyedRequestsRefuseShiftOptions(t *testing.T) {
+	tests := map[string]struct {
+		handler func(http.ResponseWriter, *http.Request, zlogger.Zlogrus)
+		body    string
+		status  int
+	}{
+		"mac":                {GetZypherMac, `{"Key":"k","Message":"hello"}`, http.StatusOK},
+		"mac with shift":     {GetZypherMac, `{"Key":"k","Message":"hello","Shift":3}`, http.StatusBadRequest},
+		"mac with alternate": {GetZypherMac, `{"Key":"k","Message":"hello","Alternate":true}`, http.StatusBadRequest},
+		"derive":             {GetZypherDerive, `{"Secret":"s","Salt":"salt","Length":32}`, http.StatusOK},
+		"derive with shift":  {GetZypherDerive, `{"Secret":"s","Salt":"salt","Length":32,"ShiftCount":2}`, http.StatusBadRequest},
+	}
+
+	for name, tt := range tests {
+		rec := serve(tt.handler, http.MethodPost, "/zypher/key", tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%v: got status %d, wanted %d: %s", name, rec.Code, tt.status, rec.Body)
+		}
+	}
+}
+
+func TestZypherExplainQueryWithBody(t *testing.T) {
+	rec := serve(GetZypher, http.MethodPost, "/zypher?explain=true", `{"Txt":"hello world"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, wanted %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var explained dtos.ZypherExplainDto
+	if err := json.NewDecoder(rec.Body).Decode(&explained); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if explained.Result == "" || len(explained.Steps) == 0 {
+		t.Errorf("got %+v, wanted the steps explained", explained)
+	}
+
+	tests := map[string]struct {
+		target string
+		body   string
+		status int
+	}{
+		"invalid explain":    {"/zypher?explain=maybe", `{"Txt":"hello world"}`, http.StatusBadRequest},
+		"explain with batch": {"/zypher?explain=true", `{"Inputs":["a","b"]}`, http.StatusBadRequest},
+		"explain off":        {"/zypher?explain=false", `{"Txt":"hello world"}`, http.StatusOK},
+	}
+	for name, tt := range tests {
+		if rec := serve(GetZypher, http.MethodPost, tt.target, tt.body); rec.Code != tt.status {
+			t.Errorf("%v: got status %d, wanted %d: %s", name, rec.Code, tt.status, rec.Body)
+		}
+	}
+}
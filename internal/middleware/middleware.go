@@ -23,3 +23,25 @@ func (w *WrappedWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 	w.StatusCode = status
 }
+
+// redactedParams are query params that carry credentials or plaintext, they are kept out of request logs
+var redactedParams = []string{"token", "txt"}
+
+// RedactedURI is the request uri with the values of credential query params replaced so it can be logged
+func RedactedURI(r *http.Request) string {
+	query := r.URL.Query()
+	redacted := false
+	for _, param := range redactedParams {
+		if query.Has(param) {
+			query.Set(param, "redacted")
+			redacted = true
+		}
+	}
+
+	if !redacted {
+		return r.URL.RequestURI()
+	}
+	uri := *r.URL
+	uri.RawQuery = query.Encode()
+	return uri.RequestURI()
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedactedURI(t *testing.T) {
+	cases := []struct {
+		uri  string
+		want string
+	}{
+		{"/schedule?token=abc123", "/schedule?token=redacted"},
+		{"/schedule?period=week&token=abc123", "/schedule?period=week&token=redacted"},
+		{"/schedule", "/schedule"},
+		{"/zypher?txt=secret&hasher=sha256", "/zypher?hasher=sha256&txt=redacted"},
+		{"/zypher/analysis/a1?wait=1", "/zypher/analysis/a1?wait=1"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, c.uri, nil)
+		if got := RedactedURI(r); got != c.want {
+			t.Errorf("got %v, wanted %v", got, c.want)
+		}
+		if r.URL.Query().Get("token") == "redacted" {
+			t.Errorf("got the request's own url redacted for %v, wanted it left alone", c.uri)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// SlotHold reserves a start/end range for a visitor while they fill out the booking form
+type SlotHold struct {
+	Holder    string
+	Start     time.Time
+	End       time.Time
+	ExpiresAt time.Time
+}
+
+func NewSlotHold(holder string, start, end time.Time, ttl time.Duration) *SlotHold {
+	return &SlotHold{
+		Holder:    holder,
+		Start:     start,
+		End:       end,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+}
+
+func (h SlotHold) Overlaps(start, end time.Time) bool {
+	return start.Before(h.End) && h.Start.Before(end)
+}
+
+func (h SlotHold) Expired() bool {
+	return !time.Now().Before(h.ExpiresAt)
+}
+
+// Pending strips the holder so the hold can be shown to every viewer
+func (h SlotHold) Pending() PendingSlot {
+	return PendingSlot{
+		Start:     h.Start,
+		End:       h.End,
+		ExpiresAt: h.ExpiresAt,
+	}
+}
+
+func (h SlotHold) ViewAttr() string {
+	return fmt.Sprintf("SlotHold: {Holder: %v, Start: %v, End: %v, Expires: %v}", h.Holder, h.Start.String(), h.End.String(), h.ExpiresAt.String())
+}
+
+type PendingSlot struct {
+	Start     time.Time
+	End       time.Time
+	ExpiresAt time.Time
+}
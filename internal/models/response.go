@@ -31,6 +31,8 @@ func NewPortfolioResponse(d Detail, e []Experience, s []Skill) *PortfolioRespons
 type ScheduleResponse struct {
 	CurrentPeriod Period
 	Agenda        *Schedule
+	Pending       []PendingSlot    // slots held by visitors mid booking, filled in per request and never cached
+	Versions      map[string]int64 // schedule version of each day in the period, filled in per request and never cached
 }
 
 func NewScheduleResponse(curPeriod Period, sched *Schedule) *ScheduleResponse {
@@ -66,12 +68,14 @@ type TaskInsertResponse struct {
 type TaskDeleteResponse struct {
 	Tid      string
 	DelCount int64
+	Task     Task
 }
 
-func NewTaskDeleteResponse(tid string, count int64) TaskDeleteResponse {
+func NewTaskDeleteResponse(tid string, count int64, tsk Task) TaskDeleteResponse {
 	return TaskDeleteResponse{
 		Tid:      tid,
 		DelCount: count,
+		Task:     tsk,
 	}
 }
 
@@ -103,6 +107,7 @@ type TaskEditResponse struct {
 	MatchedCount int64
 	UpdatedCount int64
 	Task         Task
+	Previous     Task `json:"-"` // the task before the edit, only kept for the broadcast
 }
 
 func NewTaskEditResponse(mcount, ucount int64, tsk Task) *TaskEditResponse {
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Session ties a visitor's token to the uid that owns their tasks, it outlives any single websocket connection
+type Session struct {
+	Uid       string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func NewSession(uid string, ttl time.Duration) *Session {
+	now := time.Now().UTC()
+	return &Session{
+		Uid:       uid,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+func (s Session) ViewAttr() string {
+	return fmt.Sprintf("Session: {Uid: %v, Created: %v, Expires: %v}", s.Uid, s.CreatedAt.String(), s.ExpiresAt.String())
+}
@@ -16,6 +16,7 @@ type Task struct {
 	Method    enums.TaskType     `bson:"method"`
 	Tid       string             `bson:"tid"`
 	User      string             `bson:"user"`
+	Status    enums.TaskStatus   `bson:"status"`
 }
 
 type Tasklist []Task
@@ -65,6 +66,16 @@ func WithUser(uid string) func(*Task) {
 	}
 }
 
+func WithStatus(status enums.TaskStatus) func(*Task) {
+	return func(t *Task) {
+		t.Status = status
+	}
+}
+
+func (t Task) Overlaps(start, end time.Time) bool {
+	return start.Before(t.EndTime) && t.StartTime.Before(end)
+}
+
 func (t *Task) Date() string {
 	yr, month, day := t.StartTime.Date()
 	return fmt.Sprintf("%v-%v-%v", day, month, yr)
@@ -116,3 +127,8 @@ func (t *Task) TaskDayKey() string {
 func (t *Task) TaskHrKey() int {
 	return t.StartTime.Hour()
 }
+
+// TaskCellKey is the weekday and hour composite used to key the schedule grid
+func (t *Task) TaskCellKey() string {
+	return MakeCompositeKey(t.WeekDay(), t.TaskHrKey())
+}
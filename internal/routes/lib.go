@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"strings"
@@ -75,6 +76,19 @@ func handleRequestTimeout(r *http.Request, w http.ResponseWriter) {
 	http.Error(w, "request time out", http.StatusInternalServerError)
 }
 
+// isAdminRequest checks the bearer token against the configured admin key, an unset key rejects every request
+func isAdminRequest(r *http.Request, apiKey string) bool {
+	if apiKey == "" {
+		return false
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) == 1
+}
+
 func log(m, lvl string) {
 	switch strings.ToLower(lvl) {
 	case "debug":
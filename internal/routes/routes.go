@@ -2,6 +2,7 @@ package routes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Z3DRP/zportfolio-service/config"
 	"github.com/Z3DRP/zportfolio-service/internal/handlers"
@@ -28,10 +29,26 @@ var wsManager *wsman.Manager
 
 func NewServer(sconfig config.ZServerConfig) (*http.Server, error) {
 	// todo maybe pass in logger to manager??
+	// one manager for the whole server so broadcasts and presence reach every connection, its context outlives any single request
+	wsManager = wsman.NewManager(context.Background(), logger)
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /about", getAbout)
 	mux.HandleFunc("POST /zypher", getZypher)
+	mux.HandleFunc("POST /unzyph", getUnzyph)
+	mux.HandleFunc("POST /zypher/file", getZypherFile)
+	mux.HandleFunc("POST /zypher/mac", getZypherMac)
+	mux.HandleFunc("POST /zypher/derive", getZypherDerive)
+	mux.HandleFunc("POST /zypher/analysis", startZypherAnalysis)
+	mux.HandleFunc("GET /zypher/analysis/{id}", getZypherAnalysis)
+	mux.HandleFunc("POST /zypher/strength", getZypherStrength)
+	mux.HandleFunc("GET /zypher/wasm/{asset}", getZypherWasm)
 	mux.HandleFunc("GET /schedule", serveWS)
+	mux.HandleFunc("POST /session", createSession)
+	mux.Handle("GET /admin/connections", adminMiddleware(http.HandlerFunc(getConnections)))
+	mux.Handle("POST /admin/ws/ticket", adminMiddleware(http.HandlerFunc(createOwnerTicket)))
+	mux.Handle("GET /admin/ws", ownerWSMiddleware(http.HandlerFunc(serveOwnerWS)))
+	mux.Handle("GET /admin/events", adminMiddleware(http.HandlerFunc(getEvents)))
 	// mux.HandleFunc("POST /task", handleCreateTask)
 	// mux.HandleFunc("PUT /task", handleEditTask)
 	// mux.HandleFunc("DELETE /task", handleRemoveTask)
@@ -49,14 +66,65 @@ func NewServer(sconfig config.ZServerConfig) (*http.Server, error) {
 
 // NOTE had to use wrapper around wsMan.serveWS() because needed to pass in the request otherwise could have just passed it straight into handlerFunc
 func serveWS(w http.ResponseWriter, r *http.Request) {
-	wsManager = wsman.NewManager(r.Context(), logger)
 	wsManager.ServeWS(w, r)
 }
 
+func getEvents(w http.ResponseWriter, r *http.Request) {
+	handlers.GetEvents(w, r, wsManager)
+}
+
+func serveOwnerWS(w http.ResponseWriter, r *http.Request) {
+	wsManager.ServeOwnerWS(w, r)
+}
+
+func createSession(w http.ResponseWriter, r *http.Request) {
+	handlers.CreateSession(w, r)
+}
+
+func createOwnerTicket(w http.ResponseWriter, r *http.Request) {
+	handlers.CreateOwnerTicket(w, r)
+}
+
+func getConnections(w http.ResponseWriter, r *http.Request) {
+	handlers.GetConnections(w, r, wsManager)
+}
+
 func getZypher(w http.ResponseWriter, r *http.Request) {
 	handlers.GetZypher(w, r, *logger)
 }
 
+func getZypherMac(w http.ResponseWriter, r *http.Request) {
+	handlers.GetZypherMac(w, r, *logger)
+}
+
+func getZypherDerive(w http.ResponseWriter, r *http.Request) {
+	handlers.GetZypherDerive(w, r, *logger)
+}
+
+func startZypherAnalysis(w http.ResponseWriter, r *http.Request) {
+	handlers.StartZypherAnalysis(w, r, *logger)
+}
+
+func getZypherAnalysis(w http.ResponseWriter, r *http.Request) {
+	handlers.GetZypherAnalysis(w, r, *logger)
+}
+
+func getZypherStrength(w http.ResponseWriter, r *http.Request) {
+	handlers.GetZypherStrength(w, r, *logger)
+}
+
+func getZypherWasm(w http.ResponseWriter, r *http.Request) {
+	handlers.GetZypherWasm(w, r, *logger)
+}
+
+func getZypherFile(w http.ResponseWriter, r *http.Request) {
+	handlers.GetZypherFile(w, r, *logger)
+}
+
+func getUnzyph(w http.ResponseWriter, r *http.Request) {
+	handlers.GetUnzyph(w, r, *logger)
+}
+
 func getAbout(w http.ResponseWriter, r *http.Request) {
 	handlers.GetAbout(w, r)
 }
@@ -82,6 +150,51 @@ func headerMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+func adminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminConfig, err := config.ReadAdminConfig()
+		if err != nil {
+			handleConfigReadErr(err, w)
+			return
+		}
+
+		if !isAdminRequest(r, adminConfig.ApiKey) {
+			log(fmt.Sprintf("unauthorized admin request: %s from IP: %s", middleware.RedactedURI(r), r.RemoteAddr), "debug")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ownerWSMiddleware lets the owner websocket in with the admin key like the other admin routes, or with an owner ticket
+// for browsers that cannot set the authorization header on an upgrade
+func ownerWSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminConfig, err := config.ReadAdminConfig()
+		if err != nil {
+			handleConfigReadErr(err, w)
+			return
+		}
+
+		if isAdminRequest(r, adminConfig.ApiKey) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err = wsman.RedeemOwnerTicket(r.Context(), wsman.OwnerTicket(r)); err != nil {
+			log(fmt.Sprintf("unauthorized owner websocket: %v from IP: %s", err, r.RemoteAddr), "debug")
+			if errors.Is(err, wsman.ErrInvalidOwnerTicket) {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "could not check owner ticket", http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func contextMiddleware(next http.Handler, timeout time.Duration) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), timeout)
@@ -99,7 +212,7 @@ func loggerMiddleware(next http.Handler) http.Handler {
 			StatusCode:     http.StatusOK,
 		}
 		next.ServeHTTP(wrapped, r)
-		logger.MustDebug(fmt.Sprintf("Method: %s, URI: %s, IP: %s, Duration: %v, Status: %v", r.Method, r.RequestURI, r.RemoteAddr, start, wrapped.StatusCode))
+		logger.MustDebug(fmt.Sprintf("Method: %s, URI: %s, IP: %s, Duration: %v, Status: %v", r.Method, middleware.RedactedURI(r), r.RemoteAddr, start, wrapped.StatusCode))
 	})
 }
 
@@ -34,6 +34,8 @@ func GenerateID(idType string) (string, error) {
 		prefix = "USR"
 	case "availability":
 		prefix = "AVB"
+	case "connection":
+		prefix = "CON"
 	default:
 		prefix = ""
 	}
@@ -1,6 +1,8 @@
 package wsman
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,19 +12,32 @@ import (
 )
 
 type Client struct {
+	Id            string // opaque id so connections can be listed without exposing addresses
 	Connection    *websocket.Conn
 	Manager       *Manager
 	CurrentPeriod *models.Period
+	Identity      *models.Session // nil for visitors that only watch the schedule
+	IsOwner       bool
+	ConnectedAt   time.Time
 	MessageQue    chan Event
+	codec         eventCodec
+	rateWindows   map[string]*rateWindow
 }
 
 type ClientList map[*websocket.Conn]*Client
 
-func NewClient(conn *websocket.Conn, manager *Manager) *Client {
+// large enough to hold a full replay without blocking the reader
+const messageQueSize = 256
+
+func NewClient(id string, conn *websocket.Conn, manager *Manager) *Client {
 	return &Client{
-		Connection: conn,
-		Manager:    manager,
-		MessageQue: make(chan Event),
+		Id:          id,
+		Connection:  conn,
+		Manager:     manager,
+		ConnectedAt: time.Now().UTC(),
+		MessageQue:  make(chan Event, messageQueSize),
+		codec:       codecFor(conn.Subprotocol()),
+		rateWindows: make(map[string]*rateWindow),
 	}
 }
 
@@ -30,12 +45,28 @@ func (c *Client) SetPeriod(p *models.Period) {
 	c.CurrentPeriod = p
 }
 
+// holder identifies the client's slot hold, a session keeps the hold across reconnects
+func (c *Client) holder() string {
+	if c.Identity != nil {
+		return c.Identity.Uid
+	}
+	return c.Id
+}
+
+// Send queues an event for the writer without blocking, events are dropped when the client is not keeping up
+func (c *Client) Send(evnt Event) {
+	select {
+	case c.MessageQue <- evnt:
+	default:
+		c.Manager.logger.MustDebug(fmt.Sprintf("message queue full, dropping %v event for conn: %v", evnt.Type, c.Connection.RemoteAddr()))
+	}
+}
+
 func (c *Client) ReadMessages() {
 	defer func() {
 		c.Manager.RemoveClient(c)
 	}()
 
-	c.Connection.SetReadLimit(512)
 	c.Connection.SetPongHandler(c.pongHandler)
 
 	if err := c.Connection.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
@@ -44,8 +75,9 @@ func (c *Client) ReadMessages() {
 	}
 
 	for {
-		var message Event
-		err := c.Connection.ReadJSON(&message)
+		// set per message so a handler registered after the client connected can raise the limit
+		c.Connection.SetReadLimit(c.Manager.maxEventSize())
+		_, data, err := c.Connection.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				c.Manager.logger.MustDebug(err.Error())
@@ -53,12 +85,56 @@ func (c *Client) ReadMessages() {
 			break
 		}
 
+		message, err := c.codec.Decode(data)
+		if err != nil {
+			c.rejectEvent(ErrEventValidation{
+				Violations: []FieldViolation{{Field: "", Rule: "decode", Message: "message could not be decoded as an event"}},
+			})
+			continue
+		}
+
+		if err = c.Manager.validateEvent(message, len(data)); err != nil {
+			var verr ErrEventValidation
+			if errors.As(err, &verr) {
+				c.rejectEvent(verr)
+			}
+			continue
+		}
+
 		if err = c.Manager.routeEvent(message, c); err != nil {
 			c.Manager.logger.MustDebug(err.Error())
+
+			var unknown ErrUnknownEvent
+			var limited ErrRateLimited
+			switch {
+			case errors.As(err, &unknown):
+				c.reply(EventUnknown, unknown)
+			case errors.As(err, &limited):
+				c.reply(EventRateLimited, map[string]interface{}{
+					"event":        limited.Event,
+					"retryAfterMs": limited.RetryAfter.Milliseconds(),
+				})
+			}
 		}
 	}
 }
 
+// rejectEvent tells the client which fields failed validation instead of silently dropping the event
+func (c *Client) rejectEvent(verr ErrEventValidation) {
+	c.Manager.logger.MustDebug(fmt.Sprintf("rejected event from conn %v: %v", c.Id, verr.Error()))
+	c.reply(EventValidationError, verr)
+}
+
+// reply queues a typed error event for the client
+func (c *Client) reply(evntType string, payload interface{}) {
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		c.Manager.logger.MustDebug(err.Error())
+		return
+	}
+	c.Send(Event{Type: evntType, Payload: rawPayload})
+}
+
 func (c *Client) WriteMessages() {
 	ticker := time.NewTicker(pingInterval)
 	defer func() {
@@ -75,7 +151,13 @@ func (c *Client) WriteMessages() {
 				return
 			}
 
-			if err := c.Connection.WriteJSON(message); err != nil {
+			data, err := c.codec.Encode(message)
+			if err != nil {
+				c.Manager.logger.MustDebug(err.Error())
+				continue
+			}
+
+			if err = c.Connection.WriteMessage(c.codec.MessageType(), data); err != nil {
 				c.Manager.logger.MustDebug(err.Error())
 			}
 			c.Manager.logger.MustDebug("message written successfully")
@@ -0,0 +1,121 @@
+package wsman
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	ProtocolJSON    = "zp.json.v1"
+	ProtocolMsgPack = "zp.msgpack.v1"
+)
+
+// eventCodec converts events to and from the wire format negotiated for a connection, handlers read payloads with Event.Decode
+// so a payload is only ever decoded from the format it arrived in
+type eventCodec interface {
+	MessageType() int
+	Encode(evnt Event) ([]byte, error)
+	Decode(data []byte) (Event, error)
+}
+
+// codecFor picks the codec for the subprotocol the upgrader agreed on, clients that ask for nothing get json
+func codecFor(subprotocol string) eventCodec {
+	if subprotocol == ProtocolMsgPack {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) MessageType() int {
+	return websocket.TextMessage
+}
+
+func (jsonCodec) Encode(evnt Event) ([]byte, error) {
+	return json.Marshal(evnt)
+}
+
+func (jsonCodec) Decode(data []byte) (Event, error) {
+	var evnt Event
+	err := json.Unmarshal(data, &evnt)
+	return evnt, err
+}
+
+// wireEvent mirrors Event with a decoded payload so msgpack can encode it natively instead of as a json string,
+// outbound payloads are built as json so times and the like keep the same shape for both encodings
+type wireEvent struct {
+	Type    string      `msgpack:"type"`
+	Payload interface{} `msgpack:"payload"`
+	Seq     int64       `msgpack:"seq,omitempty"`
+}
+
+// packedEvent is how an inbound msgpack event is read, the payload stays msgpack until a handler decodes it into its own type
+type packedEvent struct {
+	Type    string             `msgpack:"type"`
+	Payload msgpack.RawMessage `msgpack:"payload"`
+	Seq     int64              `msgpack:"seq,omitempty"`
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) MessageType() int {
+	return websocket.BinaryMessage
+}
+
+func (msgpackCodec) Encode(evnt Event) ([]byte, error) {
+	wevnt := wireEvent{Type: evnt.Type, Seq: evnt.Seq}
+	if evnt.packed != nil {
+		// an inbound msgpack event being echoed back is already in the right format
+		wevnt.Payload = evnt.packed
+	} else if len(evnt.Payload) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(evnt.Payload))
+		dec.UseNumber()
+		if err := dec.Decode(&wevnt.Payload); err != nil {
+			return nil, fmt.Errorf("error converting %v payload for msgpack:: %w", evnt.Type, err)
+		}
+		wevnt.Payload = packNumbers(wevnt.Payload)
+	}
+	return msgpack.Marshal(wevnt)
+}
+
+// packNumbers turns the json numbers in v into int64 where they are whole so msgpack clients get integers back as integers
+func packNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case map[string]interface{}:
+		for key, elem := range val {
+			val[key] = packNumbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range val {
+			val[i] = packNumbers(elem)
+		}
+	}
+	return v
+}
+
+func (msgpackCodec) Decode(data []byte) (Event, error) {
+	var pevnt packedEvent
+	if err := msgpack.Unmarshal(data, &pevnt); err != nil {
+		return Event{}, err
+	}
+	return Event{Type: pevnt.Type, Seq: pevnt.Seq, packed: pevnt.Payload}, nil
+}
+
+// decodePacked reads a msgpack payload with the json field names, numbers in an interface come back as int64, uint64 or float64
+func decodePacked(packed msgpack.RawMessage, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(packed))
+	dec.SetCustomStructTag("json")
+	dec.UseLooseInterfaceDecoding(true)
+	return dec.Decode(v)
+}
@@ -0,0 +1,113 @@
+package wsman
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodecFor(t *testing.T) {
+	if _, ok := codecFor(ProtocolMsgPack).(msgpackCodec); !ok {
+		t.Errorf("got %T for %v, wanted msgpackCodec", codecFor(ProtocolMsgPack), ProtocolMsgPack)
+	}
+	for _, subprotocol := range []string{ProtocolJSON, ""} {
+		if _, ok := codecFor(subprotocol).(jsonCodec); !ok {
+			t.Errorf("got %T for %q, wanted jsonCodec", codecFor(subprotocol), subprotocol)
+		}
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	sent := Event{Type: EventTaskAdded, Payload: json.RawMessage(`{"Day":"2024-10-07","Version":3,"Ratio":0.5,"Task":{"Tid":"t1"}}`), Seq: 42}
+
+	for _, codec := range []eventCodec{jsonCodec{}, msgpackCodec{}} {
+		data, err := codec.Encode(sent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := codec.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Type != sent.Type || got.Seq != sent.Seq {
+			t.Errorf("%T: got %v seq %v, wanted %v seq %v", codec, got.Type, got.Seq, sent.Type, sent.Seq)
+		}
+
+		var delta struct {
+			Day     string
+			Version int64
+			Ratio   float64
+			Task    struct{ Tid string }
+		}
+		if err = got.Decode(&delta); err != nil {
+			t.Fatal(err)
+		}
+		if delta.Day != "2024-10-07" || delta.Version != 3 || delta.Ratio != 0.5 || delta.Task.Tid != "t1" {
+			t.Errorf("%T: got %+v, wanted the sent delta", codec, delta)
+		}
+	}
+}
+
+func TestMsgpackPayloadDecodesIntoEventType(t *testing.T) {
+	// what a msgpack client sends, the payload is a msgpack map and never json
+	data, err := msgpack.Marshal(map[string]interface{}{
+		"type":    EventResume,
+		"payload": map[string]interface{}{"lastSeq": int8(7), "periodStart": "2024-10-07T00:00:00Z"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evnt, err := msgpackCodec{}.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evnt.Payload != nil {
+		t.Errorf("got json payload %s, wanted the payload left as msgpack", evnt.Payload)
+	}
+
+	var resume EvntResume
+	if err = evnt.Decode(&resume); err != nil {
+		t.Fatal(err)
+	}
+	if resume.LastSeq != 7 || resume.PeriodStart != "2024-10-07T00:00:00Z" {
+		t.Errorf("got %+v, wanted lastSeq 7 from 2024-10-07T00:00:00Z", resume)
+	}
+
+	// the schema rules see the same values a json client would send
+	if err = testManager(t).validateEvent(evnt, len(data)); err != nil {
+		t.Errorf("got %v, wanted the msgpack resume to validate", err)
+	}
+
+	// echoing the event back keeps its payload
+	echoed, err := msgpackCodec{}.Encode(evnt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wire map[string]interface{}
+	if err = msgpack.Unmarshal(echoed, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if payload, ok := wire["payload"].(map[string]interface{}); !ok || payload["periodStart"] != resume.PeriodStart {
+		t.Errorf("got %v, wanted the resume payload echoed", wire["payload"])
+	}
+}
+
+func TestMsgpackEmptyPayload(t *testing.T) {
+	data, err := msgpack.Marshal(map[string]interface{}{"type": EventResync, "payload": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evnt, err := msgpackCodec{}.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !evnt.empty() {
+		t.Errorf("got a payload of %v, wanted it empty", evnt.packed)
+	}
+	if err = testManager(t).validateEvent(evnt, len(data)); err != nil {
+		t.Errorf("got %v, wanted an empty resync to validate", err)
+	}
+}
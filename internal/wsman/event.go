@@ -3,15 +3,47 @@ package wsman
 import (
 	"context"
 	"encoding/json"
+
+	"github.com/Z3DRP/zportfolio-service/internal/dtos"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
 
 type Event struct {
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload"`
+	Seq     int64           `json:"seq,omitempty"` // set on hub broadcasts so clients can resume after a dropped connection
+	packed  msgpack.RawMessage
+}
+
+// Decode reads the payload into v, a payload from a msgpack client is decoded from msgpack without going through json
+func (e Event) Decode(v interface{}) error {
+	if e.packed != nil {
+		return decodePacked(e.packed, v)
+	}
+	return json.Unmarshal(e.Payload, v)
+}
+
+// empty reports whether the event came without a payload
+func (e Event) empty() bool {
+	if e.packed != nil {
+		return len(e.packed) == 0 || (len(e.packed) == 1 && e.packed[0] == msgpcode.Nil)
+	}
+	return len(e.Payload) == 0 || string(e.Payload) == "null"
 }
 
 type EventHandler func(ctx context.Context, clnt *Client, evnt Event) error
 
+// sendDto queues a response through the client's writer so it goes out in the negotiated encoding
+func sendDto[P dtos.Payloader](clnt *Client, msg dtos.EventDto[P]) error {
+	payload, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+	clnt.Send(Event{Type: msg.Type, Payload: payload})
+	return nil
+}
+
 const (
 	EventFetchSchedule     = "fetch_schedule"
 	EventBroadcastSchedule = "broadcast_schedule"
@@ -21,6 +53,27 @@ const (
 	EventInsertResponse    = "insert_response"
 	EventUpdateResponse    = "update_response"
 	EventRemovedResponse   = "remove_response"
+	EventResume            = "resume"
+	EventPresence          = "presence"
+	EventHoldSlot          = "hold_slot"
+	EventReleaseSlot       = "release_slot"
+	EventHoldResponse      = "hold_response"
+	EventSlotHeld          = "slot_held"
+	EventSlotReleased      = "slot_released"
+	EventTaskAdded         = "task_added"
+	EventTaskUpdated       = "task_updated"
+	EventTaskRemoved       = "task_removed"
+	EventResync            = "resync"
+	EventValidationError   = "validation_error"
+	EventTaskRequested     = "task_requested"
+	EventTaskChanged       = "task_changed"
+	EventVisitorArrived    = "visitor_arrived"
+	EventEmailFailed       = "email_failed"
+	EventAcceptTask        = "accept_task"
+	EventDeclineTask       = "decline_task"
+	EventBlockSlot         = "block_slot"
+	EventUnknown           = "unknown_event"
+	EventRateLimited       = "rate_limited"
 )
 
 type EvntTaskDelete struct {
@@ -45,3 +98,24 @@ type EvntTaskUpsert struct {
 	Phone   string `json:"phone"`
 	Roles   string `json:"roles"`
 }
+
+type EvntResume struct {
+	LastSeq     int64  `json:"lastSeq"`
+	PeriodStart string `json:"periodStart"`
+	PeriodEnd   string `json:"periodEnd"`
+}
+
+type EvntHoldSlot struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type EvntOwnerTask struct {
+	Tid string `json:"tid"`
+}
+
+type EvntBlockSlot struct {
+	Start  string `json:"start"`
+	End    string `json:"end"`
+	Detail string `json:"detail"`
+}
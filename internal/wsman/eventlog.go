@@ -0,0 +1,321 @@
+package wsman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/controller"
+	"github.com/Z3DRP/zportfolio-service/internal/dacstore"
+	"github.com/Z3DRP/zportfolio-service/internal/dtos"
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+)
+
+const (
+	eventLogSize = 256 // number of broadcasts kept in redis for replay
+	maxReplayGap = 128 // past this many missed events a full schedule snapshot is cheaper than a replay
+	// schedule versions are kept per utc day, a period is never longer than a quarter so this bounds the versions read per view
+	maxVersionDays = 93
+)
+
+// loggedEvent is what gets written to the event log, the period is kept so replays can be filtered the same way broadcasts are
+type loggedEvent struct {
+	Event       Event     `json:"event"`
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+	Delta       bool      `json:"delta,omitempty"`
+}
+
+// broadcast stamps msg with the next sequence number, records it in the event log and queues it for every client that receives prd
+func (m *Manager) broadcast(ctx context.Context, prd *models.Period, msg Event, delta bool) {
+	if err := m.logEvent(ctx, prd, &msg, delta); err != nil {
+		// still deliver the event, clients just wont be able to replay it
+		m.logger.MustDebug(err.Error())
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, clnt := range m.Clients {
+		if receives(clnt.CurrentPeriod, prd.StartDate, prd.EndDate, delta) {
+			clnt.Send(msg)
+		}
+	}
+}
+
+// receives reports whether a client viewing view gets an event for the period start to end. A task delta goes to every view
+// that touches its day so no view misses a version of a day it tracks, a full schedule only goes to views inside its period
+func receives(view *models.Period, start, end time.Time, delta bool) bool {
+	if view == nil {
+		return false
+	}
+	if delta {
+		return view.StartDate.Before(end) && start.Before(view.EndDate)
+	}
+	// utils.IsInRange leaves out a view ending with the period, which would miss its own schedule
+	return !view.StartDate.Before(start) && !view.EndDate.After(end)
+}
+
+func (m *Manager) logEvent(ctx context.Context, prd *models.Period, msg *Event, delta bool) error {
+	cacheClient, err := dacstore.NewRedisClient(ctx)
+	if err != nil {
+		return dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+	}
+
+	seq, err := dacstore.NextEventSeq(ctx, cacheClient)
+	if err != nil {
+		return utils.NewCacheOpErr("incrementing", "event sequence", err)
+	}
+	msg.Seq = seq
+
+	record, err := json.Marshal(loggedEvent{Event: *msg, PeriodStart: prd.StartDate, PeriodEnd: prd.EndDate, Delta: delta})
+	if err != nil {
+		return utils.NewJsonEncodeErr(msg, err)
+	}
+
+	if err = dacstore.AppendEventLog(ctx, cacheClient, seq, record, eventLogSize); err != nil {
+		return utils.NewCacheOpErr("writing", "event log", err)
+	}
+	return nil
+}
+
+// versionDay is the day a task's changes are versioned under, the utc day it starts on
+func versionDay(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// dayPeriod is the utc day t falls on
+func dayPeriod(t time.Time) *models.Period {
+	start := t.UTC().Truncate(24 * time.Hour)
+	return &models.Period{StartDate: start, EndDate: start.Add(24 * time.Hour)}
+}
+
+// versionDays lists the days prd touches, the days a client viewing prd tracks versions for
+func versionDays(prd *models.Period) ([]string, error) {
+	days := make([]string, 0)
+	for day := dayPeriod(prd.StartDate); day.StartDate.Before(prd.EndDate); day = dayPeriod(day.EndDate) {
+		if len(days) == maxVersionDays {
+			return nil, utils.NewInvalidOperationErr("schedule versions", fmt.Sprintf("periods can be at most %d days", maxVersionDays), nil)
+		}
+		days = append(days, versionDay(day.StartDate))
+	}
+	return days, nil
+}
+
+// scheduleVersions returns the version of every day in prd, a delta continues from the version of the day it is for
+func scheduleVersions(ctx context.Context, prd *models.Period) (map[string]int64, error) {
+	days, err := versionDays(prd)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheClient, err := dacstore.NewRedisClient(ctx)
+	if err != nil {
+		return nil, dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+	}
+
+	versions, err := dacstore.CurrentScheduleVersions(ctx, cacheClient, days)
+	if err != nil {
+		return nil, utils.NewCacheOpErr("reading", "schedule versions", err)
+	}
+	return versions, nil
+}
+
+func nextScheduleVersion(ctx context.Context, day string) (int64, error) {
+	cacheClient, err := dacstore.NewRedisClient(ctx)
+	if err != nil {
+		return 0, dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+	}
+
+	version, err := dacstore.NextScheduleVersion(ctx, cacheClient, day)
+	if err != nil {
+		return 0, utils.NewCacheOpErr("incrementing", "schedule version", err)
+	}
+	return version, nil
+}
+
+// scheduleEvent builds a full broadcast_schedule event for prd straight from the database
+func scheduleEvent(ctx context.Context, prd *models.Period, versions map[string]int64) (Event, error) {
+	result, err := controller.FetchSchedule(ctx, prd.StartDate, prd.EndDate)
+	if err != nil {
+		periodStr := fmt.Sprintf("Period [start: %v, end: %v]", prd.StartDate.String(), prd.EndDate.String())
+		return Event{}, utils.ErrFetchRecords{RecordType: "schedule", Msg: periodStr, Err: err}
+	}
+
+	response, ok := result.(*models.ScheduleResponse)
+	if !ok {
+		return Event{}, utils.NewTypeCastErr(result, models.ScheduleResponse{}, nil)
+	}
+
+	if err = attachHolds(ctx, response); err != nil {
+		return Event{}, err
+	}
+
+	response.Versions = versions
+	rawResponse, err := json.Marshal(dtos.NewScheduleDto(response))
+	if err != nil {
+		return Event{}, utils.NewJsonEncodeErr(response, err)
+	}
+
+	return Event{Type: EventBroadcastSchedule, Payload: rawResponse}, nil
+}
+
+// HandleResume replays the broadcasts a reconnecting client missed, or sends a full snapshot when the gap is too large to replay
+func HandleResume(ctx context.Context, clnt *Client, evnt Event) error {
+	select {
+	case <-ctx.Done():
+		closeOnTimeout(clnt, evnt.Type)
+	default:
+		var resumeEvnt EvntResume
+		if err := evnt.Decode(&resumeEvnt); err != nil {
+			return utils.NewJsonDecodeErr(resumeEvnt, err)
+		}
+
+		if resumeEvnt.PeriodStart != "" || resumeEvnt.PeriodEnd != "" {
+			periodStart, err := time.Parse(time.RFC3339, resumeEvnt.PeriodStart)
+			if err != nil {
+				return utils.NewTimeParseErr(resumeEvnt.PeriodStart, "Period Start", err)
+			}
+
+			periodEnd, err := time.Parse(time.RFC3339, resumeEvnt.PeriodEnd)
+			if err != nil {
+				return utils.NewTimeParseErr(resumeEvnt.PeriodEnd, "Period End", err)
+			}
+			clnt.Manager.SetClientPeriod(clnt, &models.Period{StartDate: periodStart, EndDate: periodEnd})
+		}
+
+		if clnt.CurrentPeriod == nil {
+			return utils.NewMissingDataErr("period", "RFC3339 periodStart and periodEnd", nil)
+		}
+
+		cacheClient, err := dacstore.NewRedisClient(ctx)
+		if err != nil {
+			return dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+		}
+
+		currentSeq, err := dacstore.CurrentEventSeq(ctx, cacheClient)
+		if err != nil {
+			return utils.NewCacheOpErr("reading", "event sequence", err)
+		}
+
+		if resumeEvnt.LastSeq == currentSeq {
+			return nil
+		}
+
+		oldestSeq, err := dacstore.OldestEventSeq(ctx, cacheClient)
+		if err != nil {
+			return utils.NewCacheOpErr("reading", "event log", err)
+		}
+
+		if needsSnapshot(resumeEvnt.LastSeq, currentSeq, oldestSeq) {
+			return sendSnapshot(ctx, clnt, currentSeq)
+		}
+
+		missed, err := dacstore.FetchEventsSince(ctx, cacheClient, resumeEvnt.LastSeq)
+		if err != nil {
+			return utils.NewCacheOpErr("reading", "event log", err)
+		}
+
+		replay, err := replayFor(clnt.CurrentPeriod, resumeEvnt.LastSeq, currentSeq, missed)
+		if errors.Is(err, errReplayGap) {
+			return sendSnapshot(ctx, clnt, currentSeq)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, logged := range replay {
+			clnt.Send(logged)
+		}
+
+		clnt.Manager.logger.MustDebug(fmt.Sprintf("replayed %v events after seq %v for conn: %v", len(replay), resumeEvnt.LastSeq, clnt.Connection.RemoteAddr()))
+	}
+
+	return nil
+}
+
+// needsSnapshot reports whether a client that last saw lastSeq has to be sent a snapshot instead of a replay. A last seq ahead
+// of the hub means the log was reset, one behind the oldest entry means events were trimmed
+func needsSnapshot(lastSeq, currentSeq, oldestSeq int64) bool {
+	return lastSeq > currentSeq || currentSeq-lastSeq > maxReplayGap || oldestSeq == 0 || lastSeq+1 < oldestSeq
+}
+
+// errReplayGap means the log is missing an event between a client's last seq and the current one, a broadcast whose log
+// write failed is still delivered live so the log can have holes
+var errReplayGap = errors.New("event log has a gap")
+
+// replayFor decodes the events logged after lastSeq up to currentSeq and keeps the ones a client viewing view would have received
+func replayFor(view *models.Period, lastSeq, currentSeq int64, missed []string) ([]Event, error) {
+	replay := make([]Event, 0, len(missed))
+	next := lastSeq + 1
+	for _, raw := range missed {
+		var logged loggedEvent
+		if err := json.Unmarshal([]byte(raw), &logged); err != nil {
+			return nil, utils.NewJsonDecodeErr(logged, err)
+		}
+
+		// events logged after currentSeq was read get sent live
+		if logged.Event.Seq > currentSeq {
+			break
+		}
+		if logged.Event.Seq != next {
+			return nil, errReplayGap
+		}
+		next++
+
+		if receives(view, logged.PeriodStart, logged.PeriodEnd, logged.Delta) {
+			replay = append(replay, logged.Event)
+		}
+	}
+
+	if next != currentSeq+1 {
+		return nil, errReplayGap
+	}
+	return replay, nil
+}
+
+// HandleResync sends a full snapshot to a client that noticed a gap in the versions of a day it received deltas for
+func HandleResync(ctx context.Context, clnt *Client, evnt Event) error {
+	select {
+	case <-ctx.Done():
+		closeOnTimeout(clnt, evnt.Type)
+	default:
+		if clnt.CurrentPeriod == nil {
+			return utils.NewMissingDataErr("period", "a fetched schedule before resync", nil)
+		}
+
+		cacheClient, err := dacstore.NewRedisClient(ctx)
+		if err != nil {
+			return dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+		}
+
+		currentSeq, err := dacstore.CurrentEventSeq(ctx, cacheClient)
+		if err != nil {
+			return utils.NewCacheOpErr("reading", "event sequence", err)
+		}
+
+		return sendSnapshot(ctx, clnt, currentSeq)
+	}
+
+	return nil
+}
+
+func sendSnapshot(ctx context.Context, clnt *Client, seq int64) error {
+	versions, err := scheduleVersions(ctx, clnt.CurrentPeriod)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := scheduleEvent(ctx, clnt.CurrentPeriod, versions)
+	if err != nil {
+		return err
+	}
+
+	// stamping the snapshot with the current seq lets the client pick up from here on its next resume
+	snapshot.Seq = seq
+	clnt.Send(snapshot)
+	return nil
+}
@@ -0,0 +1,178 @@
+package wsman
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+)
+
+var week = &models.Period{
+	StartDate: time.Date(2024, time.October, 7, 0, 0, 0, 0, time.UTC),
+	EndDate:   time.Date(2024, time.October, 14, 0, 0, 0, 0, time.UTC),
+}
+
+func TestVersionDays(t *testing.T) {
+	chicago := time.FixedZone("CDT", -5*60*60)
+	cases := []struct {
+		name string
+		prd  *models.Period
+		want []string
+	}{
+		{"week", week, []string{"2024-10-07", "2024-10-08", "2024-10-09", "2024-10-10", "2024-10-11", "2024-10-12", "2024-10-13"}},
+		{"inclusive end", &models.Period{StartDate: week.StartDate, EndDate: week.StartDate.Add(47*time.Hour + 59*time.Minute)}, []string{"2024-10-07", "2024-10-08"}},
+		{"local times", &models.Period{StartDate: time.Date(2024, time.October, 7, 20, 0, 0, 0, chicago), EndDate: time.Date(2024, time.October, 8, 8, 0, 0, 0, chicago)}, []string{"2024-10-08"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := versionDays(c.prd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, c.want) {
+				t.Errorf("got %v, wanted %v", got, c.want)
+			}
+		})
+	}
+
+	long := &models.Period{StartDate: week.StartDate, EndDate: week.StartDate.AddDate(1, 0, 0)}
+	if _, err := versionDays(long); err == nil {
+		t.Errorf("got no error for a year long period, wanted one")
+	}
+}
+
+func TestDeltaDayMatchesVersionDays(t *testing.T) {
+	// a task late on the 9th in chicago is early on the 10th in utc, its delta has to be versioned under a day the views track
+	start := time.Date(2024, time.October, 9, 21, 30, 0, 0, time.FixedZone("CDT", -5*60*60))
+	days, err := versionDays(week)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day := versionDay(start); day != "2024-10-10" || !slices.Contains(days, day) {
+		t.Errorf("got day %v, wanted 2024-10-10 within %v", day, days)
+	}
+
+	prd := dayPeriod(start)
+	if !receives(week, prd.StartDate, prd.EndDate, true) {
+		t.Errorf("got no delta for a week view containing %v, wanted it", prd)
+	}
+}
+
+func TestReceives(t *testing.T) {
+	tuesday := dayPeriod(week.StartDate.Add(30 * time.Hour))
+	nextMonday := dayPeriod(week.EndDate)
+	cases := []struct {
+		name  string
+		view  *models.Period
+		prd   *models.Period
+		delta bool
+		want  bool
+	}{
+		{"no view", nil, tuesday, true, false},
+		{"delta inside the view", week, tuesday, true, true},
+		{"delta after the view", week, nextMonday, true, false},
+		{"delta on a view starting mid day", &models.Period{StartDate: tuesday.StartDate.Add(12 * time.Hour), EndDate: week.EndDate}, tuesday, true, true},
+		{"schedule of the same period", week, week, false, true},
+		{"schedule of one day in the view", week, tuesday, false, false},
+		{"schedule containing the view", tuesday, week, false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := receives(c.view, c.prd.StartDate, c.prd.EndDate, c.delta); got != c.want {
+				t.Errorf("got %v, wanted %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNeedsSnapshot(t *testing.T) {
+	cases := []struct {
+		name                           string
+		lastSeq, currentSeq, oldestSeq int64
+		want                           bool
+	}{
+		{"replayable", 10, 15, 1, false},
+		{"first logged event missed", 0, 3, 1, false},
+		{"log reset", 20, 15, 1, true},
+		{"empty log", 10, 15, 0, true},
+		{"trimmed", 10, 300, 12, true},
+		{"oldest is the next event", 11, 15, 12, false},
+		{"gap too large", 10, 10 + maxReplayGap + 1, 1, true},
+		{"gap at the limit", 10, 10 + maxReplayGap, 1, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := needsSnapshot(c.lastSeq, c.currentSeq, c.oldestSeq); got != c.want {
+				t.Errorf("got %v, wanted %v", got, c.want)
+			}
+		})
+	}
+}
+
+func logged(t *testing.T, seq int64, prd *models.Period, delta bool) string {
+	t.Helper()
+	record, err := json.Marshal(loggedEvent{Event: Event{Type: EventTaskAdded, Seq: seq}, PeriodStart: prd.StartDate, PeriodEnd: prd.EndDate, Delta: delta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(record)
+}
+
+func TestReplayFor(t *testing.T) {
+	tuesday := dayPeriod(week.StartDate.Add(30 * time.Hour))
+	nextMonday := dayPeriod(week.EndDate)
+	missed := []string{
+		logged(t, 5, tuesday, true),
+		logged(t, 6, nextMonday, true),
+		logged(t, 7, week, false),
+		logged(t, 8, tuesday, true),
+	}
+
+	replay, err := replayFor(week, 4, 7, missed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seqs []int64
+	for _, evnt := range replay {
+		seqs = append(seqs, evnt.Seq)
+	}
+	// 6 is outside the view and 8 was logged after the current seq was read, it goes out live
+	if want := []int64{5, 7}; !slices.Equal(seqs, want) {
+		t.Errorf("got seqs %v, wanted %v", seqs, want)
+	}
+
+	if replay, err = replayFor(week, 7, 7, nil); err != nil || len(replay) != 0 {
+		t.Errorf("got %v and %v, wanted nothing to replay", replay, err)
+	}
+}
+
+func TestReplayForGaps(t *testing.T) {
+	cases := []struct {
+		name    string
+		lastSeq int64
+		seqs    []int64
+	}{
+		{"hole in the middle", 4, []int64{5, 7}},
+		{"first event missing", 4, []int64{6, 7}},
+		{"last event missing", 4, []int64{5, 6}},
+		{"nothing logged", 4, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var missed []string
+			for _, seq := range c.seqs {
+				missed = append(missed, logged(t, seq, week, false))
+			}
+			if _, err := replayFor(week, c.lastSeq, 7, missed); !errors.Is(err, errReplayGap) {
+				t.Errorf("got %v, wanted %v", err, errReplayGap)
+			}
+		})
+	}
+}
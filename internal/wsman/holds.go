@@ -0,0 +1,283 @@
+package wsman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/enums"
+	"github.com/Z3DRP/zportfolio-service/internal/controller"
+	"github.com/Z3DRP/zportfolio-service/internal/dacstore"
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	holdTTL     = 5 * time.Minute // long enough to fill out the booking form
+	holdLockTTL = 5 * time.Second
+	// everything run under the lock, a booking's task insert included, is stopped after this so the lock can not
+	// expire and let another booking in while it is still running
+	holdLockWork    = 4 * time.Second
+	holdLockRetries = 20
+	holdLockBackoff = 50 * time.Millisecond
+)
+
+var ErrSlotHoldLocked = errors.New("could not acquire slot hold lock")
+
+// withHoldLock runs fn while holding the redis lock that serializes hold and booking checks across every instance,
+// fn gets a ctx that ends before the lock expires
+func (m *Manager) withHoldLock(ctx context.Context, cacheClient *redis.Client, fn func(context.Context) error) error {
+	token, err := utils.GenToken()
+	if err != nil {
+		return utils.NewIdGenErr("slot hold lock", err)
+	}
+	lockToken := fmt.Sprintf("%x", token)
+
+	for i := 0; i < holdLockRetries; i++ {
+		acquired, err := dacstore.AcquireSlotHoldLock(ctx, cacheClient, lockToken, holdLockTTL)
+		if err != nil {
+			return utils.NewCacheOpErr("acquiring", "slot hold lock", err)
+		}
+
+		if acquired {
+			defer func() {
+				if err := dacstore.ReleaseSlotHoldLock(context.Background(), cacheClient, lockToken); err != nil {
+					m.logger.MustDebug(err.Error())
+				}
+			}()
+
+			lockedCtx, cancel := context.WithTimeout(ctx, holdLockWork)
+			defer cancel()
+			return fn(lockedCtx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return utils.NewTimeoutErr("slot hold lock", ctx.Err())
+		case <-time.After(holdLockBackoff):
+		}
+	}
+	return ErrSlotHoldLocked
+}
+
+// conflictingHold returns the first hold owned by someone other than holder that overlaps start and end
+func conflictingHold(holds []models.SlotHold, holder string, start, end time.Time) *models.SlotHold {
+	for _, hold := range holds {
+		if hold.Holder != holder && hold.Overlaps(start, end) {
+			return &hold
+		}
+	}
+	return nil
+}
+
+// slotBook is what a booking needs from the stores, bookSlot only goes through it so the booking rules can be tested without redis or mongo
+type slotBook interface {
+	// Lock runs fn while no other hold or booking check can run, fn has to finish before the ctx it is given ends
+	Lock(ctx context.Context, fn func(context.Context) error) error
+	Holds(ctx context.Context) ([]models.SlotHold, error)
+	// Tasks returns the stored tasks that overlap start to end
+	Tasks(ctx context.Context, start, end time.Time) (models.Tasklist, error)
+	Insert(ctx context.Context, start, end time.Time, detail, uid string) (*models.TaskInsertResponse, error)
+}
+
+// bookSlot checks the slot against other visitors holds and the tasks already stored and inserts the task,
+// all under the hold lock so two bookings for the same slot can not both pass the checks
+func bookSlot(ctx context.Context, book slotBook, holder, uid string, start, end time.Time, detail string) (*models.TaskInsertResponse, error) {
+	var inserted *models.TaskInsertResponse
+	err := book.Lock(ctx, func(ctx context.Context) error {
+		holds, err := book.Holds(ctx)
+		if err != nil {
+			return utils.NewCacheOpErr("reading", "slot holds", err)
+		}
+
+		if conflict := conflictingHold(holds, holder, start, end); conflict != nil {
+			return utils.NewInvalidOperationErr("task create", "slot is being booked by another visitor", nil)
+		}
+
+		tasks, err := book.Tasks(ctx, start, end)
+		if err != nil {
+			return utils.ErrFetchRecords{RecordType: "task", Msg: "overlapping tasks", Err: err}
+		}
+
+		if len(tasks) > 0 {
+			return utils.NewInvalidOperationErr("task create", "slot is already booked", nil)
+		}
+
+		inserted, err = book.Insert(ctx, start, end, detail, uid)
+		return err
+	})
+	return inserted, err
+}
+
+// storeBook books against redis and mongo
+type storeBook struct {
+	manager     *Manager
+	cacheClient *redis.Client
+}
+
+func (b storeBook) Lock(ctx context.Context, fn func(context.Context) error) error {
+	return b.manager.withHoldLock(ctx, b.cacheClient, fn)
+}
+
+func (b storeBook) Holds(ctx context.Context) ([]models.SlotHold, error) {
+	return dacstore.FetchSlotHolds(ctx, b.cacheClient)
+}
+
+func (b storeBook) Tasks(ctx context.Context, start, end time.Time) (models.Tasklist, error) {
+	return controller.FetchOverlappingTasks(ctx, start, end)
+}
+
+func (b storeBook) Insert(ctx context.Context, start, end time.Time, detail, uid string) (*models.TaskInsertResponse, error) {
+	nwTask, err := controller.CreateTask(ctx, start, end, detail, uid)
+	if err != nil {
+		return nil, utils.NewDbErr(enums.Insert.String(), "task", err)
+	}
+
+	tskRes, ok := nwTask.(*models.TaskInsertResponse)
+	if !ok {
+		return nil, utils.NewTypeCastErr(nwTask, models.TaskInsertResponse{}, nil)
+	}
+	return tskRes, nil
+}
+
+// attachHolds fills in the pending slots for the schedule period, holds are live state so this happens after caching
+func attachHolds(ctx context.Context, sched *models.ScheduleResponse) error {
+	cacheClient, err := dacstore.NewRedisClient(ctx)
+	if err != nil {
+		return dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+	}
+
+	holds, err := dacstore.FetchSlotHolds(ctx, cacheClient)
+	if err != nil {
+		return utils.NewCacheOpErr("reading", "slot holds", err)
+	}
+
+	pending := make([]models.PendingSlot, 0)
+	for _, hold := range holds {
+		if hold.Overlaps(sched.CurrentPeriod.StartDate, sched.CurrentPeriod.EndDate) {
+			pending = append(pending, hold.Pending())
+		}
+	}
+	sched.Pending = pending
+	return nil
+}
+
+func HandleHoldSlot(ctx context.Context, clnt *Client, evnt Event) error {
+	select {
+	case <-ctx.Done():
+		closeOnTimeout(clnt, evnt.Type)
+	default:
+		var holdEvnt EvntHoldSlot
+		if err := evnt.Decode(&holdEvnt); err != nil {
+			return utils.NewJsonDecodeErr(holdEvnt, err)
+		}
+
+		holdStart, err := time.Parse(time.RFC3339, holdEvnt.Start)
+		if err != nil {
+			return utils.NewTimeParseErr(holdEvnt.Start, "start date", err)
+		}
+
+		holdEnd, err := time.Parse(time.RFC3339, holdEvnt.End)
+		if err != nil {
+			return utils.NewTimeParseErr(holdEvnt.End, "end date", err)
+		}
+
+		if !holdStart.Before(holdEnd) {
+			return utils.NewInvalidOperationErr("slot hold", "start must be before end", nil)
+		}
+
+		cacheClient, err := dacstore.NewRedisClient(ctx)
+		if err != nil {
+			return dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+		}
+
+		hold := models.NewSlotHold(clnt.holder(), holdStart, holdEnd, holdTTL)
+		var replaced *models.SlotHold
+		err = clnt.Manager.withHoldLock(ctx, cacheClient, func(ctx context.Context) error {
+			holds, err := dacstore.FetchSlotHolds(ctx, cacheClient)
+			if err != nil {
+				return utils.NewCacheOpErr("reading", "slot holds", err)
+			}
+
+			if conflict := conflictingHold(holds, clnt.holder(), holdStart, holdEnd); conflict != nil {
+				return utils.NewInvalidOperationErr("slot hold", "slot is being booked by another visitor", nil)
+			}
+
+			if replaced, err = dacstore.SetSlotHold(ctx, cacheClient, *hold, holdTTL); err != nil {
+				return utils.NewCacheOpErr("writing", "slot hold", err)
+			}
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		rawHold, err := json.Marshal(hold.Pending())
+		if err != nil {
+			return utils.NewJsonEncodeErr(hold, err)
+		}
+		clnt.Send(Event{Type: EventHoldResponse, Payload: rawHold})
+
+		if replaced != nil {
+			clnt.Manager.BroadcastHoldDelta(EventSlotReleased, *replaced)
+		}
+		clnt.Manager.BroadcastHoldDelta(EventSlotHeld, *hold)
+	}
+	return nil
+}
+
+func HandleReleaseSlot(ctx context.Context, clnt *Client, evnt Event) error {
+	select {
+	case <-ctx.Done():
+		closeOnTimeout(clnt, evnt.Type)
+	default:
+		cacheClient, err := dacstore.NewRedisClient(ctx)
+		if err != nil {
+			return dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+		}
+
+		removed, err := dacstore.RemoveSlotHold(ctx, cacheClient, clnt.holder())
+		if err != nil {
+			return utils.NewCacheOpErr("removing", "slot hold", err)
+		}
+
+		if removed != nil {
+			clnt.Manager.BroadcastHoldDelta(EventSlotReleased, *removed)
+		}
+	}
+	return nil
+}
+
+// releaseHold drops a disconnected client's hold so the slot opens back up before the ttl runs out
+func (m *Manager) releaseHold(clnt *Client) {
+	m.RLock()
+	for _, other := range m.Clients {
+		if other.holder() == clnt.holder() {
+			// the same session is still connected in another tab
+			m.RUnlock()
+			return
+		}
+	}
+	m.RUnlock()
+
+	ctx := context.TODO()
+	cacheClient, err := dacstore.NewRedisClient(ctx)
+	if err != nil {
+		m.logger.MustDebug(dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err).Error())
+		return
+	}
+
+	removed, err := dacstore.RemoveSlotHold(ctx, cacheClient, clnt.holder())
+	if err != nil {
+		m.logger.MustDebug(utils.NewCacheOpErr("removing", "slot hold", err).Error())
+		return
+	}
+
+	if removed != nil {
+		m.BroadcastHoldDelta(EventSlotReleased, *removed)
+	}
+}
@@ -0,0 +1,139 @@
+package wsman
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/enums"
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+)
+
+// memoryBook keeps holds and tasks in memory, its lock stands in for the redis hold lock
+type memoryBook struct {
+	mu    sync.Mutex
+	holds []models.SlotHold
+	tasks models.Tasklist
+}
+
+func (b *memoryBook) Lock(ctx context.Context, fn func(context.Context) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return fn(ctx)
+}
+
+func (b *memoryBook) Holds(ctx context.Context) ([]models.SlotHold, error) {
+	return b.holds, nil
+}
+
+func (b *memoryBook) Tasks(ctx context.Context, start, end time.Time) (models.Tasklist, error) {
+	var overlapping models.Tasklist
+	for _, tsk := range b.tasks {
+		if tsk.Status != enums.Declined && tsk.Overlaps(start, end) {
+			overlapping = append(overlapping, tsk)
+		}
+	}
+	return overlapping, nil
+}
+
+func (b *memoryBook) Insert(ctx context.Context, start, end time.Time, detail, uid string) (*models.TaskInsertResponse, error) {
+	// gives a second booking time to slip in if the checks and the insert were not under one lock
+	time.Sleep(time.Millisecond)
+	tsk := models.BuildTask(models.WithTimes(start, end), models.WithDetail(detail), models.WithUser(uid))
+	b.tasks = append(b.tasks, *tsk)
+	return &models.TaskInsertResponse{NwTask: tsk}, nil
+}
+
+var bookingStart = time.Date(2024, time.October, 7, 15, 0, 0, 0, time.UTC)
+
+func TestBookSlotConcurrent(t *testing.T) {
+	book := &memoryBook{}
+	results := make(chan error, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every booking overlaps the others by at least half an hour
+			start := bookingStart.Add(time.Duration(i) * time.Minute)
+			_, err := bookSlot(context.Background(), book, "visitor", "visitor", start, start.Add(time.Hour), "")
+			results <- err
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+
+	booked := 0
+	for err := range results {
+		var invalid utils.InvalidOperationErr
+		switch {
+		case err == nil:
+			booked++
+		case !errors.As(err, &invalid):
+			t.Errorf("got %v, wanted an invalid operation error", err)
+		}
+	}
+	if booked != 1 || len(book.tasks) != 1 {
+		t.Errorf("got %d bookings and %d stored tasks, wanted 1 of each", booked, len(book.tasks))
+	}
+}
+
+func TestBookSlotConflicts(t *testing.T) {
+	end := bookingStart.Add(time.Hour)
+	cases := []struct {
+		name   string
+		holds  []models.SlotHold
+		tasks  models.Tasklist
+		booked bool
+	}{
+		{name: "open slot", booked: true},
+		{name: "own hold", holds: []models.SlotHold{*models.NewSlotHold("visitor", bookingStart, end, holdTTL)}, booked: true},
+		{name: "other hold", holds: []models.SlotHold{*models.NewSlotHold("other", bookingStart.Add(30*time.Minute), end, holdTTL)}},
+		{name: "stored task", tasks: models.Tasklist{*models.BuildTask(models.WithTimes(bookingStart.Add(-30*time.Minute), bookingStart.Add(time.Minute)))}},
+		{name: "blocked slot", tasks: models.Tasklist{*models.BuildTask(models.WithTimes(bookingStart, end), models.WithStatus(enums.Blocked))}},
+		{name: "declined task", tasks: models.Tasklist{*models.BuildTask(models.WithTimes(bookingStart, end), models.WithStatus(enums.Declined))}, booked: true},
+		{name: "back to back", tasks: models.Tasklist{*models.BuildTask(models.WithTimes(end, end.Add(time.Hour)))}, booked: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			book := &memoryBook{holds: c.holds, tasks: c.tasks}
+			_, err := bookSlot(context.Background(), book, "visitor", "visitor", bookingStart, end, "")
+			if booked := err == nil; booked != c.booked {
+				t.Errorf("got booked %v with err %v, wanted booked %v", booked, err, c.booked)
+			}
+		})
+	}
+}
+
+// deadlineBook gives the locked work a deadline like the redis lock does and records the ctx the insert ran with
+type deadlineBook struct {
+	*memoryBook
+	insertCtx context.Context
+}
+
+func (b *deadlineBook) Lock(ctx context.Context, fn func(context.Context) error) error {
+	lockedCtx, cancel := context.WithTimeout(ctx, holdLockWork)
+	defer cancel()
+	return b.memoryBook.Lock(lockedCtx, fn)
+}
+
+func (b *deadlineBook) Insert(ctx context.Context, start, end time.Time, detail, uid string) (*models.TaskInsertResponse, error) {
+	b.insertCtx = ctx
+	return b.memoryBook.Insert(ctx, start, end, detail, uid)
+}
+
+func TestBookSlotInsertsUnderLockDeadline(t *testing.T) {
+	book := &deadlineBook{memoryBook: &memoryBook{}}
+	if _, err := bookSlot(context.Background(), book, "visitor", "visitor", bookingStart, bookingStart.Add(time.Hour), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline, ok := book.insertCtx.Deadline()
+	if !ok || time.Until(deadline) >= holdLockTTL {
+		t.Errorf("got deadline %v set %v, wanted the insert stopped before the %v lock expires", deadline, ok, holdLockTTL)
+	}
+}
@@ -0,0 +1,145 @@
+package wsman
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/enums"
+	"github.com/Z3DRP/zportfolio-service/internal/adapters"
+	"github.com/Z3DRP/zportfolio-service/internal/controller"
+	"github.com/Z3DRP/zportfolio-service/internal/dtos"
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+)
+
+// OwnerUid owns the tasks created when the owner blocks out a slot
+const OwnerUid = "owner"
+
+// ServeOwnerWS upgrades the owner's connection, the route is expected to have checked the admin key or redeemed an owner ticket
+func (m *Manager) ServeOwnerWS(w http.ResponseWriter, r *http.Request) {
+	client := m.connect(w, r)
+	if client == nil {
+		return
+	}
+	client.IsOwner = true
+	m.start(client)
+}
+
+// NotifyOwners sends an event to every owner connection, owner events are live only so they are not sequenced or logged
+func (m *Manager) NotifyOwners(evntType string, payload interface{}) {
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		m.logger.MustDebug(utils.NewJsonEncodeErr(payload, err).Error())
+		return
+	}
+
+	msg := Event{Type: evntType, Payload: rawPayload}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, clnt := range m.Clients {
+		if clnt.IsOwner {
+			clnt.Send(msg)
+		}
+	}
+}
+
+func (m *Manager) notifyTaskChange(action string, tsk models.Task, visitor *adapters.UserData) {
+	evntType := EventTaskChanged
+	if action == "requested" {
+		evntType = EventTaskRequested
+	}
+	m.NotifyOwners(evntType, dtos.NewTaskNoticeDto(action, tsk, visitor))
+}
+
+func (m *Manager) notifyEmailFailed(tsk models.Task, kind enums.ZemailType, recipient string, err error) {
+	m.NotifyOwners(EventEmailFailed, dtos.EmailFailureDto{
+		Tid:       tsk.Tid,
+		Kind:      kind.String(),
+		Recipient: recipient,
+		Error:     err.Error(),
+	})
+}
+
+func HandleAcceptTask(ctx context.Context, clnt *Client, evnt Event) error {
+	return setTaskStatus(ctx, clnt, evnt, enums.Accepted, "accepted")
+}
+
+func HandleDeclineTask(ctx context.Context, clnt *Client, evnt Event) error {
+	return setTaskStatus(ctx, clnt, evnt, enums.Declined, "declined")
+}
+
+func setTaskStatus(ctx context.Context, clnt *Client, evnt Event, status enums.TaskStatus, action string) error {
+	select {
+	case <-ctx.Done():
+		closeOnTimeout(clnt, evnt.Type)
+	default:
+		var ownerEvnt EvntOwnerTask
+		if err := evnt.Decode(&ownerEvnt); err != nil {
+			return utils.NewJsonDecodeErr(ownerEvnt, err)
+		}
+
+		if ownerEvnt.Tid == "" {
+			return utils.NewMissingDataErr("task id", "string", nil)
+		}
+
+		results, err := controller.SetTaskStatus(ctx, ownerEvnt.Tid, status)
+		if err != nil {
+			return utils.NewDbErr("update status", "task", err)
+		}
+
+		rsltRes, ok := results.(*models.TaskEditResponse)
+		if !ok {
+			return utils.NewTypeCastErr(results, models.TaskEditResponse{}, nil)
+		}
+
+		clnt.Manager.notifyTaskChange(action, rsltRes.Task, nil)
+		clnt.Manager.BroadcastTaskDelta(EventTaskUpdated, rsltRes.Task)
+	}
+
+	return nil
+}
+
+func HandleBlockSlot(ctx context.Context, clnt *Client, evnt Event) error {
+	select {
+	case <-ctx.Done():
+		closeOnTimeout(clnt, evnt.Type)
+	default:
+		var blockEvnt EvntBlockSlot
+		if err := evnt.Decode(&blockEvnt); err != nil {
+			return utils.NewJsonDecodeErr(blockEvnt, err)
+		}
+
+		blockStart, err := time.Parse(time.RFC3339, blockEvnt.Start)
+		if err != nil {
+			return utils.NewTimeParseErr(blockEvnt.Start, "start date", err)
+		}
+
+		blockEnd, err := time.Parse(time.RFC3339, blockEvnt.End)
+		if err != nil {
+			return utils.NewTimeParseErr(blockEvnt.End, "end date", err)
+		}
+
+		if !blockStart.Before(blockEnd) {
+			return utils.NewInvalidOperationErr("block slot", "start must be before end", nil)
+		}
+
+		nwTask, err := controller.BlockSlot(ctx, blockStart, blockEnd, blockEvnt.Detail, OwnerUid)
+		if err != nil {
+			return utils.NewDbErr(enums.Insert.String(), "task", err)
+		}
+
+		tskRes, ok := nwTask.(*models.TaskInsertResponse)
+		if !ok {
+			return utils.NewTypeCastErr(nwTask, models.TaskInsertResponse{}, nil)
+		}
+
+		clnt.Manager.notifyTaskChange("blocked", *tskRes.NwTask, nil)
+		clnt.Manager.BroadcastTaskDelta(EventTaskAdded, *tskRes.NwTask)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package wsman
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/dtos"
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+)
+
+// Presence counts connected visitors per viewed period, visitors that have not fetched a schedule yet only count towards the
+// total. Owner connections are left out so watching the schedule as the owner does not show up as a viewer
+func (m *Manager) Presence() dtos.PresenceDto {
+	m.RLock()
+	defer m.RUnlock()
+
+	// keyed on the formatted utc times, time.Time values parsed from different offsets do not compare equal
+	counts := make(map[[2]string]int)
+	visitors := 0
+	for _, clnt := range m.Clients {
+		if clnt.IsOwner {
+			continue
+		}
+		visitors++
+		if clnt.CurrentPeriod == nil {
+			continue
+		}
+		counts[periodKey(clnt.CurrentPeriod)]++
+	}
+
+	periods := make([]dtos.PeriodPresenceDto, 0, len(counts))
+	for prd, viewers := range counts {
+		periods = append(periods, dtos.PeriodPresenceDto{
+			PeriodStart: prd[0],
+			PeriodEnd:   prd[1],
+			Viewers:     viewers,
+		})
+	}
+
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].PeriodStart < periods[j].PeriodStart
+	})
+
+	return dtos.PresenceDto{
+		Periods: periods,
+		Total:   visitors,
+	}
+}
+
+// Connections lists the active connections with the period each one is viewing, owner connections are flagged so they can
+// be told apart from visitors
+func (m *Manager) Connections() []dtos.ConnectionDto {
+	m.RLock()
+	defer m.RUnlock()
+
+	conns := make([]dtos.ConnectionDto, 0, len(m.Clients))
+	for _, clnt := range m.Clients {
+		conn := dtos.ConnectionDto{Id: clnt.Id, Owner: clnt.IsOwner, ConnectedAt: clnt.ConnectedAt}
+		if clnt.CurrentPeriod != nil {
+			prd := periodKey(clnt.CurrentPeriod)
+			conn.PeriodStart, conn.PeriodEnd = prd[0], prd[1]
+		}
+		conns = append(conns, conn)
+	}
+
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].ConnectedAt.Before(conns[j].ConnectedAt)
+	})
+	return conns
+}
+
+func periodKey(prd *models.Period) [2]string {
+	return [2]string{prd.StartDate.UTC().Format(time.RFC3339), prd.EndDate.UTC().Format(time.RFC3339)}
+}
+
+// pushPresence sends the current viewer counts to every client, presence is live state so it is not sequenced or logged for replay
+func (m *Manager) pushPresence() {
+	presence := m.Presence()
+	rawPresence, err := json.Marshal(presence)
+	if err != nil {
+		m.logger.MustDebug(utils.NewJsonEncodeErr(presence, err).Error())
+		return
+	}
+
+	msg := Event{Type: EventPresence, Payload: rawPresence}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, clnt := range m.Clients {
+		clnt.Send(msg)
+	}
+}
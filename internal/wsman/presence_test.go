@@ -0,0 +1,59 @@
+package wsman
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/dtos"
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+func withClients(clients ...*Client) *Manager {
+	man := &Manager{Clients: make(ClientList)}
+	for _, clnt := range clients {
+		clnt.Connection = new(websocket.Conn)
+		man.Clients[clnt.Connection] = clnt
+	}
+	return man
+}
+
+func TestPresenceLeavesOutOwners(t *testing.T) {
+	// the same week seen from another offset is still one period
+	chicagoWeek := &models.Period{StartDate: week.StartDate.In(time.FixedZone("CDT", -5*60*60)), EndDate: week.EndDate}
+	man := withClients(
+		&Client{CurrentPeriod: week},
+		&Client{CurrentPeriod: chicagoWeek},
+		&Client{},
+		&Client{CurrentPeriod: week, IsOwner: true},
+		&Client{IsOwner: true},
+	)
+
+	got := man.Presence()
+	if got.Total != 3 {
+		t.Errorf("got a total of %d, wanted 3 visitors", got.Total)
+	}
+	want := dtos.PeriodPresenceDto{PeriodStart: "2024-10-07T00:00:00Z", PeriodEnd: "2024-10-14T00:00:00Z", Viewers: 2}
+	if len(got.Periods) != 1 || got.Periods[0] != want {
+		t.Errorf("got %+v, wanted %+v", got.Periods, want)
+	}
+}
+
+func TestConnectionsFlagOwners(t *testing.T) {
+	connected := time.Date(2024, time.October, 7, 9, 0, 0, 0, time.UTC)
+	man := withClients(
+		&Client{Id: "later", ConnectedAt: connected.Add(time.Minute), CurrentPeriod: week},
+		&Client{Id: "owner", ConnectedAt: connected, IsOwner: true},
+	)
+
+	got := man.Connections()
+	if len(got) != 2 || got[0].Id != "owner" || got[1].Id != "later" {
+		t.Fatalf("got %+v, wanted owner then later by connection time", got)
+	}
+	if !got[0].Owner || got[1].Owner {
+		t.Errorf("got owner flags %v and %v, wanted only the owner flagged", got[0].Owner, got[1].Owner)
+	}
+	if got[1].PeriodStart != "2024-10-07T00:00:00Z" || got[0].PeriodStart != "" {
+		t.Errorf("got periods %q and %q, wanted only the visitor's", got[0].PeriodStart, got[1].PeriodStart)
+	}
+}
@@ -0,0 +1,236 @@
+package wsman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+)
+
+var ErrHandlerRegistered = errors.New("a handler is already registered for this event")
+
+// HandlerMiddleware wraps a handler, middleware passed to RegisterHandler runs in the order it was given
+type HandlerMiddleware func(EventHandler) EventHandler
+
+// HandlerInfo describes a registered event for documentation, it plays no part in dispatching
+type HandlerInfo struct {
+	Name            string
+	Description     string
+	OwnerOnly       bool
+	SessionRequired bool
+	RateLimit       string
+	Timeout         string
+	Schema          *EventSchema
+}
+
+type registration struct {
+	info        HandlerInfo
+	handler     EventHandler
+	middlewares []HandlerMiddleware
+}
+
+type HandlerOption func(*registration)
+
+func WithDescription(desc string) HandlerOption {
+	return func(r *registration) {
+		r.info.Description = desc
+	}
+}
+
+// WithSchema validates the event before the handler runs, events without a schema only get the default size limit
+func WithSchema(schema EventSchema) HandlerOption {
+	return func(r *registration) {
+		r.info.Schema = &schema
+	}
+}
+
+func WithMiddleware(mw ...HandlerMiddleware) HandlerOption {
+	return func(r *registration) {
+		r.middlewares = append(r.middlewares, mw...)
+	}
+}
+
+func RequireOwner() HandlerOption {
+	return func(r *registration) {
+		r.info.OwnerOnly = true
+		r.middlewares = append(r.middlewares, requireOwner)
+	}
+}
+
+func RequireSession() HandlerOption {
+	return func(r *registration) {
+		r.info.SessionRequired = true
+		r.middlewares = append(r.middlewares, requireSession)
+	}
+}
+
+// RateLimit allows each connection count events of this type per window
+func RateLimit(count int, window time.Duration) HandlerOption {
+	return func(r *registration) {
+		r.info.RateLimit = fmt.Sprintf("%d per %v", count, window)
+		r.middlewares = append(r.middlewares, rateLimit(r.info.Name, count, window))
+	}
+}
+
+func WithTimeout(timeout time.Duration) HandlerOption {
+	return func(r *registration) {
+		r.info.Timeout = timeout.String()
+		r.middlewares = append(r.middlewares, func(next EventHandler) EventHandler {
+			return func(ctx context.Context, clnt *Client, evnt Event) error {
+				ctx, cancel := context.WithTimeout(ctx, timeout)
+				defer cancel()
+				return next(ctx, clnt, evnt)
+			}
+		})
+	}
+}
+
+func WithLogging() HandlerOption {
+	return func(r *registration) {
+		r.middlewares = append(r.middlewares, func(next EventHandler) EventHandler {
+			return func(ctx context.Context, clnt *Client, evnt Event) error {
+				start := time.Now()
+				err := next(ctx, clnt, evnt)
+				clnt.Manager.logger.MustDebug(fmt.Sprintf("Event: %v, Conn: %v, Duration: %v, Err: %v", evnt.Type, clnt.Id, time.Since(start), err))
+				return err
+			}
+		})
+	}
+}
+
+// RegisterHandler adds a handler for an event type, it is safe to call while clients are connected
+func (m *Manager) RegisterHandler(name string, handler EventHandler, opts ...HandlerOption) error {
+	if name == "" || handler == nil {
+		return utils.NewMissingDataErr("event handler", "EventHandler", nil)
+	}
+
+	reg := &registration{info: HandlerInfo{Name: name}, handler: handler}
+	for _, opt := range opts {
+		opt(reg)
+	}
+
+	// wrapped back to front so the first middleware given is the outermost
+	for i := len(reg.middlewares) - 1; i >= 0; i-- {
+		reg.handler = reg.middlewares[i](reg.handler)
+	}
+
+	m.handlersMu.Lock()
+	defer m.handlersMu.Unlock()
+
+	if _, ok := m.handlers[name]; ok {
+		return fmt.Errorf("%w: %v", ErrHandlerRegistered, name)
+	}
+	m.handlers[name] = reg
+	// handlers are never removed so the limit only grows, readers pick it up before their next message
+	if reg.info.Schema != nil && reg.info.Schema.MaxSize > m.readLimit.Load() {
+		m.readLimit.Store(reg.info.Schema.MaxSize)
+	}
+	return nil
+}
+
+// Describe lists every registered event with its metadata, sorted by name
+func (m *Manager) Describe() []HandlerInfo {
+	m.handlersMu.RLock()
+	defer m.handlersMu.RUnlock()
+
+	infos := make([]HandlerInfo, 0, len(m.handlers))
+	for _, reg := range m.handlers {
+		infos = append(infos, reg.info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+func (m *Manager) registration(name string) (*registration, bool) {
+	m.handlersMu.RLock()
+	defer m.handlersMu.RUnlock()
+
+	reg, ok := m.handlers[name]
+	return reg, ok
+}
+
+// ErrUnknownEvent is sent back to the client as the payload of an unknown_event event
+type ErrUnknownEvent struct {
+	Event     string   `json:"event"`
+	Supported []string `json:"supported"`
+}
+
+func (e ErrUnknownEvent) Error() string {
+	return fmt.Sprintf("%v: %v, supported events are %v", ErrEventNotSupported, e.Event, strings.Join(e.Supported, ", "))
+}
+
+func (e ErrUnknownEvent) Unwrap() error {
+	return ErrEventNotSupported
+}
+
+func (m *Manager) unknownEvent(name string) ErrUnknownEvent {
+	infos := m.Describe()
+	supported := make([]string, 0, len(infos))
+	for _, info := range infos {
+		// owner commands are left out so visitors are not told about them
+		if !info.OwnerOnly {
+			supported = append(supported, info.Name)
+		}
+	}
+	return ErrUnknownEvent{Event: name, Supported: supported}
+}
+
+// ErrRateLimited is returned when a connection sends an event more often than its registration allows
+type ErrRateLimited struct {
+	Event      string
+	RetryAfter time.Duration
+}
+
+func (e ErrRateLimited) Error() string {
+	return fmt.Sprintf("too many %v events, retry after %v", e.Event, e.RetryAfter)
+}
+
+func requireOwner(next EventHandler) EventHandler {
+	return func(ctx context.Context, clnt *Client, evnt Event) error {
+		if !clnt.IsOwner {
+			return utils.NewPermissionErr(evnt.Type, "only the owner can run this command", nil)
+		}
+		return next(ctx, clnt, evnt)
+	}
+}
+
+func requireSession(next EventHandler) EventHandler {
+	return func(ctx context.Context, clnt *Client, evnt Event) error {
+		if clnt.Identity == nil {
+			return utils.NewPermissionErr(evnt.Type, "a session is required for this event", nil)
+		}
+		return next(ctx, clnt, evnt)
+	}
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimit keeps its counters on the client, a client's events are handled one at a time by its reader so no lock is needed
+func rateLimit(name string, count int, window time.Duration) HandlerMiddleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, clnt *Client, evnt Event) error {
+			now := time.Now()
+			win, ok := clnt.rateWindows[name]
+			if !ok || now.Sub(win.start) >= window {
+				win = &rateWindow{start: now}
+				clnt.rateWindows[name] = win
+			}
+
+			if win.count >= count {
+				return ErrRateLimited{Event: evnt.Type, RetryAfter: win.start.Add(window).Sub(now)}
+			}
+			win.count++
+			return next(ctx, clnt, evnt)
+		}
+	}
+}
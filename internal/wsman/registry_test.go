@@ -0,0 +1,155 @@
+package wsman
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/Z3DRP/zportfolio-service/internal/utils"
+)
+
+func noopHandler(ctx context.Context, clnt *Client, evnt Event) error {
+	return nil
+}
+
+func testClient() *Client {
+	return &Client{rateWindows: make(map[string]*rateWindow)}
+}
+
+func dispatch(t *testing.T, man *Manager, clnt *Client, name string) error {
+	t.Helper()
+	reg, ok := man.registration(name)
+	if !ok {
+		t.Fatalf("got no registration for %v, wanted one", name)
+	}
+	return reg.handler(context.Background(), clnt, Event{Type: name})
+}
+
+func TestRegisterHandler(t *testing.T) {
+	man := &Manager{handlers: make(map[string]*registration)}
+	if err := man.RegisterHandler("ping", noopHandler); err != nil {
+		t.Fatal(err)
+	}
+	if err := man.RegisterHandler("ping", noopHandler); !errors.Is(err, ErrHandlerRegistered) {
+		t.Errorf("got %v, wanted %v", err, ErrHandlerRegistered)
+	}
+	if err := man.RegisterHandler("", noopHandler); err == nil {
+		t.Errorf("got no error for an unnamed handler, wanted one")
+	}
+
+	man.RegisterHandler("admin", noopHandler, RequireOwner())
+	var names []string
+	for _, info := range man.Describe() {
+		names = append(names, info.Name)
+	}
+	if !slices.Equal(names, []string{"admin", "ping"}) {
+		t.Errorf("got %v, wanted the events sorted by name", names)
+	}
+
+	unknown := man.unknownEvent("pong")
+	if !slices.Equal(unknown.Supported, []string{"ping"}) || !errors.Is(unknown, ErrEventNotSupported) {
+		t.Errorf("got %+v, wanted only ping listed for visitors", unknown)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	track := func(name string) HandlerMiddleware {
+		return func(next EventHandler) EventHandler {
+			return func(ctx context.Context, clnt *Client, evnt Event) error {
+				calls = append(calls, name)
+				return next(ctx, clnt, evnt)
+			}
+		}
+	}
+
+	man := &Manager{handlers: make(map[string]*registration)}
+	man.RegisterHandler("ping", func(ctx context.Context, clnt *Client, evnt Event) error {
+		calls = append(calls, "handler")
+		return nil
+	}, WithMiddleware(track("first"), track("second")))
+
+	if err := dispatch(t, man, testClient(), "ping"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first", "second", "handler"}; !slices.Equal(calls, want) {
+		t.Errorf("got %v, wanted %v", calls, want)
+	}
+}
+
+func TestRequireOwnerAndSession(t *testing.T) {
+	man := &Manager{handlers: make(map[string]*registration)}
+	man.RegisterHandler("owner_only", noopHandler, RequireOwner())
+	man.RegisterHandler("session_only", noopHandler, RequireSession())
+
+	visitor := testClient()
+	var denied utils.PermissionErr
+	for _, name := range []string{"owner_only", "session_only"} {
+		if err := dispatch(t, man, visitor, name); !errors.As(err, &denied) {
+			t.Errorf("%v: got %v, wanted a permission error", name, err)
+		}
+	}
+
+	owner := testClient()
+	owner.IsOwner = true
+	if err := dispatch(t, man, owner, "owner_only"); err != nil {
+		t.Errorf("got %v, wanted the owner let through", err)
+	}
+
+	withSession := testClient()
+	withSession.Identity = &models.Session{Uid: "u1"}
+	if err := dispatch(t, man, withSession, "session_only"); err != nil {
+		t.Errorf("got %v, wanted the session let through", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	man := &Manager{handlers: make(map[string]*registration)}
+	man.RegisterHandler("ping", noopHandler, RateLimit(2, time.Minute))
+
+	clnt := testClient()
+	for i := 0; i < 2; i++ {
+		if err := dispatch(t, man, clnt, "ping"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var limited ErrRateLimited
+	if err := dispatch(t, man, clnt, "ping"); !errors.As(err, &limited) || limited.RetryAfter <= 0 {
+		t.Errorf("got %v, wanted a rate limit with a retry after", err)
+	}
+
+	// the window is per connection
+	if err := dispatch(t, man, testClient(), "ping"); err != nil {
+		t.Errorf("got %v, wanted another connection let through", err)
+	}
+
+	clnt.rateWindows["ping"].start = time.Now().Add(-time.Minute)
+	if err := dispatch(t, man, clnt, "ping"); err != nil {
+		t.Errorf("got %v, wanted a new window once the old one passed", err)
+	}
+}
+
+func TestReadLimitFollowsRegistry(t *testing.T) {
+	man := testManager(t)
+	if got := man.maxEventSize(); got != builtinSchemas[EventCreateTask].MaxSize {
+		t.Errorf("got %d, wanted the largest builtin size %d", got, builtinSchemas[EventCreateTask].MaxSize)
+	}
+
+	man.RegisterHandler("upload", noopHandler, WithSchema(EventSchema{MaxSize: 1 << 16}))
+	if got := man.maxEventSize(); got != 1<<16 {
+		t.Errorf("got %d, wanted %d after a larger schema was registered", got, 1<<16)
+	}
+
+	man.RegisterHandler("tiny", noopHandler, WithSchema(EventSchema{MaxSize: 16}))
+	if got := man.maxEventSize(); got != 1<<16 {
+		t.Errorf("got %d, wanted a smaller schema to leave the limit at %d", got, 1<<16)
+	}
+
+	if got := (&Manager{handlers: make(map[string]*registration)}).maxEventSize(); got != defaultEventSize {
+		t.Errorf("got %d, wanted the default %d with nothing registered", got, defaultEventSize)
+	}
+}
@@ -2,12 +2,10 @@ package wsman
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
-	"github.com/Z3DRP/zportfolio-service/config"
 	"github.com/Z3DRP/zportfolio-service/enums"
 	"github.com/Z3DRP/zportfolio-service/internal/adapters"
 	"github.com/Z3DRP/zportfolio-service/internal/controller"
@@ -26,7 +24,7 @@ func HandleGetSchedule(ctx context.Context, clnt *Client, evnt Event) error {
 		var scheduleData models.Responser
 		var fetchSchedEvnt EvntFetchSchedule
 
-		if err := json.Unmarshal(evnt.Payload, &fetchSchedEvnt); err != nil {
+		if err := evnt.Decode(&fetchSchedEvnt); err != nil {
 			return utils.NewJsonDecodeErr(fetchSchedEvnt, err)
 		}
 
@@ -48,7 +46,7 @@ func HandleGetSchedule(ctx context.Context, clnt *Client, evnt Event) error {
 			return utils.NewTimeParseErr(fetchSchedEvnt.PeriodStart, "Period End", err)
 		}
 
-		clnt.SetPeriod(&models.Period{StartDate: periodStart, EndDate: periodEnd})
+		clnt.Manager.SetClientPeriod(clnt, &models.Period{StartDate: periodStart, EndDate: periodEnd})
 		scheduleData, err = dacstore.CheckScheduleData(ctx, cacheClient, periodStart, periodEnd)
 		var noResults *dacstore.ErrNoCacheResult
 
@@ -75,11 +73,19 @@ func HandleGetSchedule(ctx context.Context, clnt *Client, evnt Event) error {
 		}
 
 		if sdata, ok := scheduleData.(*models.ScheduleResponse); ok {
+			if err = attachHolds(ctx, sdata); err != nil {
+				return err
+			}
+
+			// deltas broadcast after this build on the versions the client starts from
+			if sdata.Versions, err = scheduleVersions(ctx, clnt.CurrentPeriod); err != nil {
+				return err
+			}
 
 			msg := dtos.NewEventDto(EventBroadcastSchedule, sdata)
 
-			if err = utils.MustSendMessage(clnt.Connection, msg); err != nil {
-				return utils.NewFailedSendEventResponse(clnt.Connection, evnt.Type, err)
+			if err = sendDto(clnt, msg); err != nil {
+				return utils.NewJsonEncodeErr(msg, err)
 			}
 
 		} else {
@@ -88,7 +94,6 @@ func HandleGetSchedule(ctx context.Context, clnt *Client, evnt Event) error {
 
 	}
 
-	clnt.Manager.BroadcastScheduleUpdate(clnt.CurrentPeriod)
 	return nil
 }
 
@@ -98,13 +103,12 @@ func HandleCreateTask(ctx context.Context, clnt *Client, evnt Event) error {
 		closeOnTimeout(clnt, evnt.Type)
 	default:
 		var createEvnt EvntTaskUpsert
-		uip := clnt.Connection.RemoteAddr().String()
-		settings, err := config.ReadZypherSettings()
-		if err != nil {
-			return utils.NewConfigFileErr("zypher config", err)
+		if clnt.Identity == nil {
+			return utils.NewPermissionErr("task create", "a session is required to request a task", nil)
 		}
+		uid := clnt.Identity.Uid
 
-		if err = json.Unmarshal(evnt.Payload, &createEvnt); err != nil {
+		if err := evnt.Decode(&createEvnt); err != nil {
 			return utils.NewJsonDecodeErr(createEvnt, err)
 		}
 
@@ -123,67 +127,61 @@ func HandleCreateTask(ctx context.Context, clnt *Client, evnt Event) error {
 			return utils.NewTimeParseErr(createEvnt.End, "end date", err)
 		}
 
-		usrInfo, err := dacstore.CheckUserData(ctx, cacheClient, uip)
+		usrInfo, err := dacstore.CheckUserData(ctx, cacheClient, uid)
 		var noResults *dacstore.ErrNoCacheResult
 
 		if err != nil {
 			if !errors.As(err, &noResults) {
 				return utils.NewCacheOpErr("reading", "user", err)
 			}
-		}
-
-		usr, ok := usrInfo.(dtos.UserDto)
-		if !ok {
-			return utils.NewTypeCastErr(usrInfo, dtos.UserDto{}, nil)
-		}
-
-		var uidKey string
-		if usr.Uid == "" {
-			uidKey, err = controller.CalculateZypher(uip, settings.Shift, settings.ShiftCount, settings.HashCount, settings.Alternate, settings.IgnSpace, settings.RestrictHash)
-			// add user to cache so when trying to edit tasks id can be checked
-			if err != nil {
-				return utils.NewZypherFailureErr("user id creation", err)
-			}
 
-			if err = dacstore.SetUserData(ctx, cacheClient, createEvnt.UsrName, createEvnt.Company, createEvnt.Phone, createEvnt.Email, createEvnt.Roles, uip, uidKey); err != nil {
+			// first request for this session, keep the visitors details so later edits can be checked against them
+			if err = dacstore.SetUserData(ctx, cacheClient, createEvnt.UsrName, createEvnt.Company, createEvnt.Phone, createEvnt.Email, createEvnt.Roles, clnt.Connection.RemoteAddr().String(), uid); err != nil {
 				return utils.NewCacheOpErr("writing", "user", err)
 			}
+		} else if _, ok := usrInfo.(*dtos.UserDto); !ok {
+			return utils.NewTypeCastErr(usrInfo, dtos.UserDto{}, nil)
 		}
 
-		nwTask, err := controller.CreateTask(ctx, taskStart, taskEnd, createEvnt.Detail, usr.Uid)
+		tskRes, err := bookSlot(ctx, storeBook{manager: clnt.Manager, cacheClient: cacheClient}, clnt.holder(), uid, taskStart, taskEnd, createEvnt.Detail)
 		if err != nil {
-			return utils.NewDbErr(enums.Insert.String(), "user", err)
+			return err
 		}
 
-		if tskRes, ok := nwTask.(*models.TaskInsertResponse); ok {
-			usrData := adapters.NewUserData(createEvnt.UsrName, createEvnt.Company, createEvnt.Email, createEvnt.Phone, createEvnt.Roles)
-			emlData := adapters.DefaultEmlInfo()
+		usrData := adapters.NewUserData(createEvnt.UsrName, createEvnt.Company, createEvnt.Email, createEvnt.Phone, createEvnt.Roles)
+		emlData := adapters.DefaultEmlInfo()
 
-			go func(logr *zlogger.Zlogrus, udata adapters.UserData, eData adapters.EmailInfo) {
-				if err = controller.SendTaskNotificationEmail(ctx, *tskRes.NwTask, usrData, emlData, enums.ZemailType(0)); err != nil {
-					logr.MustDebug(err.Error())
-				}
-			}(clnt.Manager.logger, usrData, emlData)
+		go func(logr *zlogger.Zlogrus, udata adapters.UserData, eData adapters.EmailInfo) {
+			if err := controller.SendTaskNotificationEmail(ctx, *tskRes.NwTask, udata, eData, enums.TaskCreated); err != nil {
+				logr.MustDebug(err.Error())
+				clnt.Manager.notifyEmailFailed(*tskRes.NwTask, enums.TaskCreated, "owner", err)
+			}
+		}(clnt.Manager.logger, usrData, emlData)
 
-			go func(logr *zlogger.Zlogrus, udata adapters.UserData, eData adapters.EmailInfo) {
-				if err = controller.SendThanksNotification(ctx, udata, eData); err != nil {
-					logr.MustDebug(fmt.Sprintf("could not send thank you notification to '%v' at '%v'", udata.Name, udata.Email))
-				}
-			}(clnt.Manager.logger, usrData, emlData)
+		go func(logr *zlogger.Zlogrus, udata adapters.UserData, eData adapters.EmailInfo) {
+			if err := controller.SendThanksNotification(ctx, udata, eData); err != nil {
+				logr.MustDebug(fmt.Sprintf("could not send thank you notification to '%v' at '%v'", udata.Name, udata.Email))
+				clnt.Manager.notifyEmailFailed(*tskRes.NwTask, enums.ThankYou, udata.Email, err)
+			}
+		}(clnt.Manager.logger, usrData, emlData)
 
-			msg := dtos.NewEventDto(EventInsertResponse, *tskRes)
+		// the booking went through so the visitor's hold has done its job
+		if removed, err := dacstore.RemoveSlotHold(ctx, cacheClient, clnt.holder()); err != nil {
+			clnt.Manager.logger.MustDebug(utils.NewCacheOpErr("removing", "slot hold", err).Error())
+		} else if removed != nil {
+			clnt.Manager.BroadcastHoldDelta(EventSlotReleased, *removed)
+		}
 
-			if err = utils.MustSendMessage(clnt.Connection, msg); err != nil {
-				return utils.NewFailedSendEventResponse(clnt.Connection, evnt.Type, err)
-			}
-			clnt.Manager.logger.MustDebug(fmt.Sprintf("task %v created successfully by usr %v", tskRes.NwTask.Id, usr.Uid))
+		msg := dtos.NewEventDto(EventInsertResponse, *tskRes)
 
-		} else {
-			return utils.NewTypeCastErr(nwTask, models.TaskInsertResponse{}, nil)
+		if err = sendDto(clnt, msg); err != nil {
+			return utils.NewJsonEncodeErr(msg, err)
 		}
+		clnt.Manager.logger.MustDebug(fmt.Sprintf("task %v created successfully by usr %v", tskRes.NwTask.Id, uid))
+		clnt.Manager.notifyTaskChange("requested", *tskRes.NwTask, &usrData)
+		clnt.Manager.BroadcastTaskDelta(EventTaskAdded, *tskRes.NwTask)
 	}
 
-	clnt.Manager.BroadcastScheduleUpdate(clnt.CurrentPeriod)
 	return nil
 }
 
@@ -192,9 +190,8 @@ func HandleRemoveTask(ctx context.Context, clnt *Client, evnt Event) error {
 	case <-ctx.Done():
 		closeOnTimeout(clnt, evnt.Type)
 	default:
-		var usr dtos.UserDto
-		var rmvEvnt dtos.TaskDeletePaylaod
-		if err := json.Unmarshal(evnt.Payload, &rmvEvnt); err != nil {
+		var rmvEvnt EvntTaskDelete
+		if err := evnt.Decode(&rmvEvnt); err != nil {
 			return utils.NewJsonDecodeErr(rmvEvnt, err)
 		}
 
@@ -202,51 +199,30 @@ func HandleRemoveTask(ctx context.Context, clnt *Client, evnt Event) error {
 			return utils.NewMissingDataErr("task id", "string", nil)
 		}
 
-		if rmvEvnt.Uid == "" {
-			return utils.NewMissingDataErr("user id", "string", nil)
-		}
-
-		if cacheClient, err := dacstore.NewRedisClient(ctx); err != nil {
-			return dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
-		} else {
-
-			usrInfo, err := dacstore.CheckUserData(ctx, cacheClient, clnt.Connection.RemoteAddr().String())
-			var noResults *dacstore.ErrNoCacheResult
-
-			if err != nil {
-				if errors.As(err, &noResults) {
-					return utils.NewInvalidOperationErr("task remove", "user does not own any tasks", err)
-				}
-				return err
-			}
-
-			if castedUsr, ok := usrInfo.(dtos.UserDto); !ok {
-				return utils.NewTypeCastErr(usrInfo, dtos.UserDto{}, nil)
-			} else {
-				// stupid issue with compiler not liking assigning usr to value straight from type cast
-				usr = castedUsr
-			}
-
+		if clnt.Identity == nil {
+			return utils.NewPermissionErr("task removal", "a session is required to remove a task", nil)
 		}
 
-		if rmvEvnt.Uid != usr.Uid {
-			return utils.NewInvalidOperationErr("task removal", "user must own task to remove it", nil)
+		// the uid in the payload is optional, the session decides who owns the task
+		if rmvEvnt.Uid != "" && rmvEvnt.Uid != clnt.Identity.Uid {
+			return utils.NewPermissionErr("task removal", "user must own task to remove it", nil)
 		}
 
-		delCountRes, err := controller.RemoveTask(ctx, rmvEvnt.Tid, usr.Uid)
+		delCountRes, err := controller.RemoveTask(ctx, rmvEvnt.Tid, clnt.Identity.Uid)
 		if err != nil {
 			return utils.NewDbErr("delete", "task", err)
 		}
 
 		msg := dtos.NewEventDto(EventRemovedResponse, delCountRes)
 
-		if err = utils.MustSendMessage(clnt.Connection, msg); err != nil {
-			return utils.NewFailedToSendErr(clnt.Connection, err)
+		if err = sendDto(clnt, msg); err != nil {
+			return utils.NewJsonEncodeErr(msg, err)
 		}
 
+		clnt.Manager.notifyTaskChange("removed", delCountRes.Task, nil)
+		clnt.Manager.BroadcastTaskDelta(EventTaskRemoved, delCountRes.Task)
 	}
 
-	clnt.Manager.BroadcastScheduleUpdate(clnt.CurrentPeriod)
 	return nil
 }
 
@@ -256,7 +232,7 @@ func HandleEditTask(ctx context.Context, clnt *Client, evnt Event) error {
 		closeOnTimeout(clnt, evnt.Type)
 	default:
 		var upsertEvnt EvntTaskUpsert
-		if err := json.Unmarshal(evnt.Payload, &upsertEvnt); err != nil {
+		if err := evnt.Decode(&upsertEvnt); err != nil {
 			return utils.NewJsonDecodeErr(upsertEvnt, err)
 		}
 
@@ -274,35 +250,15 @@ func HandleEditTask(ctx context.Context, clnt *Client, evnt Event) error {
 			return utils.NewTimeParseErr(upsertEvnt.End, "end date", err)
 		}
 
-		if upsertEvnt.Uid == "" {
-			return utils.NewMissingDataErr("user id", "string", nil)
+		if clnt.Identity == nil {
+			return utils.NewPermissionErr("edit task", "a session is required to edit a task", nil)
 		}
 
-		cacheClient, err := dacstore.NewRedisClient(ctx)
-		if err != nil {
-			return dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+		if upsertEvnt.Uid != "" && upsertEvnt.Uid != clnt.Identity.Uid {
+			return utils.NewPermissionErr("edit task", "user must own task to edit it", nil)
 		}
 
-		usrInfo, err := dacstore.CheckUserData(ctx, cacheClient, clnt.Connection.RemoteAddr().String())
-		var noResults *dacstore.ErrNoCacheResult
-
-		if err != nil {
-			if errors.As(err, &noResults) {
-				return utils.NewInvalidUser(upsertEvnt.Uid, err)
-
-			}
-		}
-
-		usr, ok := usrInfo.(dtos.UserDto)
-		if !ok {
-			return utils.NewTypeCastErr(usrInfo, dtos.UserDto{}, nil)
-		}
-
-		if upsertEvnt.Uid != usr.Uid {
-			return utils.NewPermissionErr("edit task", "user must own task to remove it", nil)
-		}
-
-		results, err := controller.EditTask(ctx, upsertEvnt.Tid, upsertEvnt.Uid, taskStart, taskEnd, upsertEvnt.Detail)
+		results, err := controller.EditTask(ctx, upsertEvnt.Tid, clnt.Identity.Uid, taskStart, taskEnd, upsertEvnt.Detail)
 		if err != nil {
 			return utils.NewDbErr("edit task", "task", err)
 		}
@@ -314,20 +270,23 @@ func HandleEditTask(ctx context.Context, clnt *Client, evnt Event) error {
 
 		msg := dtos.NewEventDto(EventUpdateResponse, rsltRes)
 
-		if err = utils.MustSendMessage(clnt.Connection, msg); err != nil {
-			return utils.NewFailedSendEventResponse(clnt.Connection, evnt.Type, err)
+		if err = sendDto(clnt, msg); err != nil {
+			return utils.NewJsonEncodeErr(msg, err)
 		}
+
+		clnt.Manager.notifyTaskChange("updated", rsltRes.Task, nil)
+		// a task moved to another day leaves the old day's viewers with nothing to update
+		if versionDay(rsltRes.Previous.StartTime) != versionDay(rsltRes.Task.StartTime) {
+			clnt.Manager.BroadcastTaskDelta(EventTaskRemoved, rsltRes.Previous)
+		}
+		clnt.Manager.BroadcastTaskDelta(EventTaskUpdated, rsltRes.Task)
 	}
 
-	clnt.Manager.BroadcastScheduleUpdate(clnt.CurrentPeriod)
 	return nil
 }
 
 func HandleBroadcastSchedule(ctx context.Context, clnt *Client, evnt Event) error {
-	if err := clnt.Connection.WriteJSON(evnt); err != nil {
-		clnt.Manager.logger.MustDebug(fmt.Sprintf("failed to broadcast schedule for client: %v, error: %v", clnt.Connection.RemoteAddr().String(), err))
-		return fmt.Errorf("failed to broadcast schedule for client %v, error: %v", clnt.Connection.RemoteAddr().String(), err)
-	}
+	clnt.Send(evnt)
 	return nil
 }
 
@@ -0,0 +1,102 @@
+package wsman
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Z3DRP/zportfolio-service/internal/dacstore"
+	"github.com/Z3DRP/zportfolio-service/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	SessionCookieName = "zp_session"
+	SessionTTL        = 7 * 24 * time.Hour
+	OwnerCookieName   = "zp_owner"
+	// long enough to open the socket right after fetching the ticket, each ticket only opens one connection
+	OwnerTicketTTL = 30 * time.Second
+	// browsers cannot set headers on a websocket upgrade, cross origin pages offer their credential as a subprotocol instead
+	SessionProtocol     = "zp.session."
+	OwnerTicketProtocol = "zp.ticket."
+)
+
+var (
+	ErrInvalidSession     = errors.New("session token is invalid or expired")
+	ErrInvalidOwnerTicket = errors.New("owner ticket is invalid, used or expired")
+)
+
+// SessionToken reads the session from the cookie first, then from a zp.session.<token> subprotocol that cross origin
+// websockets can send without putting the token in the url. The token query param is still read for older clients
+func SessionToken(r *http.Request) string {
+	if cookie, err := r.Cookie(SessionCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	if token := protocolCredential(r, SessionProtocol); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+// protocolCredential returns the value of the first offered subprotocol starting with prefix
+func protocolCredential(r *http.Request, prefix string) string {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if credential, found := strings.CutPrefix(protocol, prefix); found {
+			return credential
+		}
+	}
+	return ""
+}
+
+// resolveSession returns a nil session when no token was sent so visitors can still watch the schedule without one
+func resolveSession(ctx context.Context, token string) (*models.Session, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	cacheClient, err := dacstore.NewRedisClient(ctx)
+	if err != nil {
+		return nil, dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+	}
+
+	sess, err := dacstore.FetchSession(ctx, cacheClient, token)
+	if err != nil {
+		var noResults *dacstore.ErrNoCacheResult
+		if errors.As(err, &noResults) {
+			return nil, ErrInvalidSession
+		}
+		return nil, err
+	}
+	return sess, nil
+}
+
+// OwnerTicket reads the owner ticket from its cookie first and falls back to a zp.ticket.<ticket> subprotocol
+func OwnerTicket(r *http.Request) string {
+	if cookie, err := r.Cookie(OwnerCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	return protocolCredential(r, OwnerTicketProtocol)
+}
+
+// RedeemOwnerTicket uses up a ticket issued to the owner, an empty ticket is rejected like an unknown one
+func RedeemOwnerTicket(ctx context.Context, ticket string) error {
+	if ticket == "" {
+		return ErrInvalidOwnerTicket
+	}
+
+	cacheClient, err := dacstore.NewRedisClient(ctx)
+	if err != nil {
+		return dacstore.NewRedisConnErr(cacheClient.ClientID(ctx), err)
+	}
+
+	if err = dacstore.RedeemOwnerTicket(ctx, cacheClient, ticket); err != nil {
+		var noResults *dacstore.ErrNoCacheResult
+		if errors.As(err, &noResults) {
+			return ErrInvalidOwnerTicket
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package wsman
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOwnerTicket(t *testing.T) {
+	cases := []struct {
+		name      string
+		cookie    string
+		protocols string
+		want      string
+	}{
+		{"cookie", "abc", "", "abc"},
+		{"subprotocol", "", ProtocolJSON + ", " + OwnerTicketProtocol + "def", "def"},
+		{"cookie wins", "abc", OwnerTicketProtocol + "def", "abc"},
+		{"codec only", "", ProtocolMsgPack, ""},
+		{"nothing sent", "", "", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin/ws", nil)
+			if c.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: OwnerCookieName, Value: c.cookie})
+			}
+			if c.protocols != "" {
+				r.Header.Set("Sec-WebSocket-Protocol", c.protocols)
+			}
+			if got := OwnerTicket(r); got != c.want {
+				t.Errorf("got %q, wanted %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSessionToken(t *testing.T) {
+	cases := []struct {
+		name      string
+		cookie    string
+		protocols string
+		query     string
+		want      string
+	}{
+		{"cookie", "abc", "", "", "abc"},
+		{"subprotocol", "", ProtocolMsgPack + ", " + SessionProtocol + "def", "", "def"},
+		{"query", "", "", "?token=ghi", "ghi"},
+		{"subprotocol before query", "", SessionProtocol + "def", "?token=ghi", "def"},
+		{"owner ticket is not a session", "", OwnerTicketProtocol + "def", "", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/schedule"+c.query, nil)
+			if c.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: c.cookie})
+			}
+			if c.protocols != "" {
+				r.Header.Set("Sec-WebSocket-Protocol", c.protocols)
+			}
+			if got := SessionToken(r); got != c.want {
+				t.Errorf("got %q, wanted %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRedeemEmptyOwnerTicket(t *testing.T) {
+	if err := RedeemOwnerTicket(context.Background(), ""); !errors.Is(err, ErrInvalidOwnerTicket) {
+		t.Errorf("got %v, wanted %v", err, ErrInvalidOwnerTicket)
+	}
+}
@@ -0,0 +1,257 @@
+package wsman
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultEventSize = 512
+	maxDetailLength  = 1000
+)
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ().\-]{7,20}$`)
+
+// FieldRules maps a payload field to a comma separated rule list, supported rules are
+// required, rfc3339, email, phone, number, min=N and max=N where min and max count characters
+type FieldRules map[string]string
+
+// EventSchema declares the largest message accepted for an event type and the rules its payload fields must pass
+type EventSchema struct {
+	MaxSize int64
+	Fields  FieldRules
+}
+
+// builtinSchemas are attached to the built in handlers with WithSchema
+var builtinSchemas = map[string]EventSchema{
+	EventFetchSchedule: {
+		MaxSize: 512,
+		Fields: FieldRules{
+			"periodStart": "required,rfc3339",
+			"periodEnd":   "required,rfc3339",
+		},
+	},
+	EventCreateTask: {
+		MaxSize: 4096,
+		Fields: FieldRules{
+			"start":    "required,rfc3339",
+			"end":      "required,rfc3339",
+			"detail":   fmt.Sprintf("max=%d", maxDetailLength),
+			"userName": "required,max=100",
+			"company":  "max=100",
+			"email":    "required,email,max=254",
+			"phone":    "phone",
+			"roles":    "max=200",
+		},
+	},
+	EventUpdateTask: {
+		MaxSize: 4096,
+		Fields: FieldRules{
+			"tid":    "required,max=64",
+			"uid":    "max=256",
+			"start":  "required,rfc3339",
+			"end":    "required,rfc3339",
+			"detail": fmt.Sprintf("max=%d", maxDetailLength),
+		},
+	},
+	EventRemoveTask: {
+		MaxSize: 512,
+		Fields: FieldRules{
+			"tid": "required,max=64",
+			"uid": "max=256",
+		},
+	},
+	EventResume: {
+		MaxSize: 512,
+		Fields: FieldRules{
+			"lastSeq":     "required,number",
+			"periodStart": "rfc3339",
+			"periodEnd":   "rfc3339",
+		},
+	},
+	EventHoldSlot: {
+		MaxSize: 512,
+		Fields: FieldRules{
+			"start": "required,rfc3339",
+			"end":   "required,rfc3339",
+		},
+	},
+	EventAcceptTask: {
+		MaxSize: 256,
+		Fields:  FieldRules{"tid": "required,max=64"},
+	},
+	EventDeclineTask: {
+		MaxSize: 256,
+		Fields:  FieldRules{"tid": "required,max=64"},
+	},
+	EventBlockSlot: {
+		MaxSize: 2048,
+		Fields: FieldRules{
+			"start":  "required,rfc3339",
+			"end":    "required,rfc3339",
+			"detail": fmt.Sprintf("max=%d", maxDetailLength),
+		},
+	},
+	EventReleaseSlot: {MaxSize: 256},
+	EventResync:      {MaxSize: 256},
+}
+
+// maxEventSize is the connection read limit, the per event limits are checked once the type is known
+func (m *Manager) maxEventSize() int64 {
+	return max(defaultEventSize, m.readLimit.Load())
+}
+
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ErrEventValidation is sent back to the client as the payload of a validation_error event
+type ErrEventValidation struct {
+	Event      string           `json:"event"`
+	Violations []FieldViolation `json:"violations"`
+}
+
+func (e ErrEventValidation) Error() string {
+	fields := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		fields = append(fields, fmt.Sprintf("%v (%v)", v.Field, v.Rule))
+	}
+	return fmt.Sprintf("invalid %v event: %v", e.Event, strings.Join(fields, ", "))
+}
+
+// validateEvent checks the raw message size and payload against the schema registered for the event type
+func (m *Manager) validateEvent(evnt Event, size int) error {
+	schema := EventSchema{MaxSize: defaultEventSize}
+	if reg, ok := m.registration(evnt.Type); ok && reg.info.Schema != nil {
+		schema = *reg.info.Schema
+	}
+
+	verr := ErrEventValidation{Event: evnt.Type, Violations: make([]FieldViolation, 0)}
+	if int64(size) > schema.MaxSize {
+		verr.Violations = append(verr.Violations, FieldViolation{
+			Field:   "",
+			Rule:    "max_size",
+			Message: fmt.Sprintf("message of %d bytes exceeds the %d byte limit", size, schema.MaxSize),
+		})
+		return verr
+	}
+
+	if len(schema.Fields) == 0 {
+		return nil
+	}
+
+	payload := make(map[string]interface{})
+	if !evnt.empty() {
+		if err := evnt.Decode(&payload); err != nil {
+			verr.Violations = append(verr.Violations, FieldViolation{Field: "payload", Rule: "object", Message: "payload must be an object"})
+			return verr
+		}
+	}
+
+	// sorted so the violations come back in the same order every time
+	fields := make([]string, 0, len(schema.Fields))
+	for field := range schema.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		val, present := lookupField(payload, field)
+		for _, rule := range strings.Split(schema.Fields[field], ",") {
+			if msg := checkRule(rule, val, present); msg != "" {
+				verr.Violations = append(verr.Violations, FieldViolation{Field: field, Rule: rule, Message: msg})
+				// the remaining rules would only repeat the same problem
+				break
+			}
+		}
+	}
+
+	if len(verr.Violations) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// lookupField matches keys the same way encoding/json does, exact first then case insensitive
+func lookupField(payload map[string]interface{}, field string) (interface{}, bool) {
+	if val, ok := payload[field]; ok {
+		return val, true
+	}
+
+	for key, val := range payload {
+		if strings.EqualFold(key, field) {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// checkRule returns a message describing the violation or an empty string when the value passes
+func checkRule(rule string, val interface{}, present bool) string {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	if name == "required" {
+		if !present || val == nil || val == "" {
+			return "field is required"
+		}
+		return ""
+	}
+
+	// optional fields that were left out or empty have nothing else to check
+	if !present || val == nil || val == "" {
+		return ""
+	}
+
+	if name == "number" {
+		// json numbers decode as float64, msgpack ones as whichever of these fits
+		switch val.(type) {
+		case float64, int64, uint64:
+			return ""
+		}
+		return "must be a number"
+	}
+
+	str, ok := val.(string)
+	if !ok {
+		return "must be a string"
+	}
+
+	switch name {
+	case "rfc3339":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return "must be an RFC3339 timestamp"
+		}
+	case "email":
+		if addr, err := mail.ParseAddress(str); err != nil || addr.Address != str {
+			return "must be a valid email address"
+		}
+	case "phone":
+		if !phonePattern.MatchString(str) {
+			return "must be a valid phone number"
+		}
+	case "min", "max":
+		limit, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Sprintf("invalid %v rule", name)
+		}
+
+		length := utf8.RuneCountInString(str)
+		if name == "min" && length < limit {
+			return fmt.Sprintf("must be at least %d characters", limit)
+		}
+		if name == "max" && length > limit {
+			return fmt.Sprintf("must be at most %d characters", limit)
+		}
+	default:
+		return fmt.Sprintf("unknown rule %v", name)
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package wsman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testManager(t *testing.T) *Manager {
+	t.Helper()
+	man := &Manager{handlers: make(map[string]*registration)}
+	noop := func(ctx context.Context, clnt *Client, evnt Event) error { return nil }
+	for name, schema := range builtinSchemas {
+		if err := man.RegisterHandler(name, noop, WithSchema(schema)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return man
+}
+
+func violations(t *testing.T, err error) []FieldViolation {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var verr ErrEventValidation
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v, wanted an ErrEventValidation", err)
+	}
+	return verr.Violations
+}
+
+func validatePayload(t *testing.T, man *Manager, evntType, payload string) []FieldViolation {
+	t.Helper()
+	evnt := Event{Type: evntType, Payload: json.RawMessage(payload)}
+	return violations(t, man.validateEvent(evnt, len(payload)))
+}
+
+func TestValidateCreateTask(t *testing.T) {
+	man := testManager(t)
+	valid := `{"start":"2024-10-07T15:00:00Z","end":"2024-10-07T16:00:00Z","userName":"Ada","email":"ada@example.com","phone":"+1 (555) 010-2030"}`
+	if got := validatePayload(t, man, EventCreateTask, valid); len(got) != 0 {
+		t.Errorf("got %v, wanted no violations", got)
+	}
+
+	invalid := `{"START":"2024-10-07","end":"2024-10-07T16:00:00Z","userName":"` + strings.Repeat("a", 101) + `","email":"Ada <ada@example.com>","phone":"call me","company":7}`
+	want := []FieldViolation{
+		{Field: "company", Rule: "max=100", Message: "must be a string"},
+		{Field: "email", Rule: "email", Message: "must be a valid email address"},
+		{Field: "phone", Rule: "phone", Message: "must be a valid phone number"},
+		{Field: "start", Rule: "rfc3339", Message: "must be an RFC3339 timestamp"},
+		{Field: "userName", Rule: "max=100", Message: "must be at most 100 characters"},
+	}
+	// map iteration is random, the order has to hold on every run
+	for i := 0; i < 20; i++ {
+		if got := validatePayload(t, man, EventCreateTask, invalid); !slices.Equal(got, want) {
+			t.Fatalf("got %v, wanted %v", got, want)
+		}
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	man := testManager(t)
+	cases := []struct {
+		name    string
+		evnt    string
+		payload string
+		rules   []string
+	}{
+		{"missing required", EventRemoveTask, `{"uid":"u1"}`, []string{"required"}},
+		{"empty required", EventRemoveTask, `{"tid":""}`, []string{"required"}},
+		{"optional left out", EventResume, `{"lastSeq":4}`, nil},
+		{"number as string", EventResume, `{"lastSeq":"4"}`, []string{"number"}},
+		{"not an object", EventHoldSlot, `["2024-10-07T15:00:00Z"]`, []string{"object"}},
+		{"no fields to check", EventResync, `{"anything":1}`, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var rules []string
+			for _, v := range validatePayload(t, man, c.evnt, c.payload) {
+				rules = append(rules, v.Rule)
+			}
+			if !slices.Equal(rules, c.rules) {
+				t.Errorf("got rules %v, wanted %v", rules, c.rules)
+			}
+		})
+	}
+}
+
+func TestValidateSize(t *testing.T) {
+	man := testManager(t)
+	cases := []struct {
+		evnt string
+		size int
+		ok   bool
+	}{
+		{EventAcceptTask, 256, true},
+		{EventAcceptTask, 257, false},
+		{EventCreateTask, 4096, true},
+		// events without a schema get the default limit
+		{"not_registered", defaultEventSize, true},
+		{"not_registered", defaultEventSize + 1, false},
+	}
+
+	for _, c := range cases {
+		// the empty payload fails any required fields, only the size rule matters here
+		got := violations(t, man.validateEvent(Event{Type: c.evnt}, c.size))
+		tooLarge := slices.ContainsFunc(got, func(v FieldViolation) bool { return v.Rule == "max_size" })
+		if tooLarge == c.ok {
+			t.Errorf("%v of %d bytes: got %v, wanted ok %v", c.evnt, c.size, got, c.ok)
+		}
+	}
+}
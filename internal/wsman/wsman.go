@@ -1,16 +1,17 @@
 package wsman
 
 import (
+	"compress/flate"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Z3DRP/zportfolio-service/config"
-	"github.com/Z3DRP/zportfolio-service/internal/controller"
 	"github.com/Z3DRP/zportfolio-service/internal/dtos"
 	"github.com/Z3DRP/zportfolio-service/internal/models"
 	"github.com/Z3DRP/zportfolio-service/internal/utils"
@@ -29,9 +30,13 @@ var (
 
 var (
 	WebsocketUpgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin:     func(r *http.Request) bool { return config.IsValidOrigin(r.Header.Get("origin")) },
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		// permessage-deflate is only used when the client offers it
+		EnableCompression: true,
+		// server preference order, msgpack wins when a client offers both
+		Subprotocols: []string{ProtocolMsgPack, ProtocolJSON},
+		CheckOrigin:  func(r *http.Request) bool { return config.IsValidOrigin(r.Header.Get("origin")) },
 	}
 )
 
@@ -53,9 +58,11 @@ func (e ErrFailedBroadcast) Unwrap() error {
 }
 
 type Manager struct {
-	Clients  ClientList
-	logger   *zlogger.Zlogrus
-	handlers map[string]EventHandler
+	Clients    ClientList
+	logger     *zlogger.Zlogrus
+	handlers   map[string]*registration
+	handlersMu sync.RWMutex
+	readLimit  atomic.Int64 // largest MaxSize of any registered schema
 	sync.RWMutex
 }
 
@@ -63,7 +70,7 @@ func NewManager(ctx context.Context, logr *zlogger.Zlogrus) *Manager {
 	wsctx = ctx
 	man := &Manager{
 		Clients:  make(ClientList),
-		handlers: make(map[string]EventHandler),
+		handlers: make(map[string]*registration),
 		logger:   logr,
 	}
 	man.setupEventHandlers()
@@ -71,92 +78,235 @@ func NewManager(ctx context.Context, logr *zlogger.Zlogrus) *Manager {
 }
 
 func (m *Manager) ServeWS(w http.ResponseWriter, r *http.Request) {
+	// the session is checked before upgrading so a bad token gets a plain http error the browser can see
+	identity, err := resolveSession(r.Context(), SessionToken(r))
+	if err != nil {
+		m.logger.MustDebug(fmt.Sprintf("could not resolve session for %v: %v", r.RemoteAddr, err))
+		if errors.Is(err, ErrInvalidSession) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "could not resolve session", http.StatusInternalServerError)
+		return
+	}
+
+	client := m.connect(w, r)
+	if client == nil {
+		return
+	}
+	client.Identity = identity
+	m.start(client)
+}
+
+// connect upgrades the request and wraps the connection in a client, nil means the upgrade failed and was already logged
+func (m *Manager) connect(w http.ResponseWriter, r *http.Request) *Client {
 	conn, err := WebsocketUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		m.logger.MustDebug(fmt.Sprintf("error occurred while upgrading request: %v", err))
-		return
+		return nil
 	}
 
-	client := NewClient(conn, m)
+	cid, err := utils.GenerateID("connection")
+	if err != nil {
+		m.logger.MustDebug(utils.NewIdGenErr("connection", err).Error())
+		conn.Close()
+		return nil
+	}
+
+	if err = conn.SetCompressionLevel(flate.BestSpeed); err != nil {
+		m.logger.MustDebug(fmt.Sprintf("could not set compression level: %v", err))
+	}
+
+	return NewClient(cid, conn, m)
+}
+
+func (m *Manager) start(client *Client) {
 	m.AddClient(client)
 
 	go client.ReadMessages()
 	go client.WriteMessages()
 }
 
-// NOTE might have to update this to either wrap the actual handlers in the anom func or update the anom func to the handler itself
 func (m *Manager) setupEventHandlers() {
-	m.handlers[EventFetchSchedule] = HandleGetSchedule
-	m.handlers[EventCreateTask] = HandleCreateTask
-	m.handlers[EventUpdateTask] = HandleEditTask
-	m.handlers[EventRemoveTask] = HandleRemoveTask
-	m.handlers[EventBroadcastSchedule] = HandleBroadcastSchedule
+	m.register(EventFetchSchedule, HandleGetSchedule,
+		WithDescription("loads the schedule for a period and subscribes the connection to its updates"),
+		WithSchema(builtinSchemas[EventFetchSchedule]),
+		RateLimit(30, time.Minute),
+	)
+	m.register(EventCreateTask, HandleCreateTask,
+		WithDescription("requests a meeting in an open slot"),
+		WithSchema(builtinSchemas[EventCreateTask]),
+		RequireSession(),
+		RateLimit(5, time.Minute),
+		WithLogging(),
+	)
+	m.register(EventUpdateTask, HandleEditTask,
+		WithDescription("changes the time or detail of a task the session owns"),
+		WithSchema(builtinSchemas[EventUpdateTask]),
+		RequireSession(),
+		RateLimit(10, time.Minute),
+		WithLogging(),
+	)
+	m.register(EventRemoveTask, HandleRemoveTask,
+		WithDescription("removes a task the session owns"),
+		WithSchema(builtinSchemas[EventRemoveTask]),
+		RequireSession(),
+		RateLimit(10, time.Minute),
+		WithLogging(),
+	)
+	m.register(EventBroadcastSchedule, HandleBroadcastSchedule,
+		WithDescription("echoes a schedule event back to the connection"),
+		RateLimit(10, time.Minute),
+	)
+	m.register(EventResume, HandleResume,
+		WithDescription("replays the broadcasts missed since lastSeq or sends a fresh snapshot"),
+		WithSchema(builtinSchemas[EventResume]),
+		RateLimit(10, time.Minute),
+	)
+	m.register(EventHoldSlot, HandleHoldSlot,
+		WithDescription("holds a slot while the booking form is filled out"),
+		WithSchema(builtinSchemas[EventHoldSlot]),
+		RateLimit(20, time.Minute),
+	)
+	m.register(EventReleaseSlot, HandleReleaseSlot,
+		WithDescription("releases the connection's slot hold"),
+		WithSchema(builtinSchemas[EventReleaseSlot]),
+		RateLimit(20, time.Minute),
+	)
+	m.register(EventResync, HandleResync,
+		WithDescription("sends a full schedule snapshot after a missed delta"),
+		WithSchema(builtinSchemas[EventResync]),
+		RateLimit(10, time.Minute),
+	)
+	m.register(EventAcceptTask, HandleAcceptTask,
+		WithDescription("accepts a requested task"),
+		WithSchema(builtinSchemas[EventAcceptTask]),
+		RequireOwner(),
+		WithLogging(),
+	)
+	m.register(EventDeclineTask, HandleDeclineTask,
+		WithDescription("declines a requested task"),
+		WithSchema(builtinSchemas[EventDeclineTask]),
+		RequireOwner(),
+		WithLogging(),
+	)
+	m.register(EventBlockSlot, HandleBlockSlot,
+		WithDescription("marks a range of the schedule as unavailable"),
+		WithSchema(builtinSchemas[EventBlockSlot]),
+		RequireOwner(),
+		WithLogging(),
+	)
+}
+
+// register is for the built in handlers, a failure here is a programming mistake so it is only logged
+func (m *Manager) register(name string, handler EventHandler, opts ...HandlerOption) {
+	if err := m.RegisterHandler(name, handler, opts...); err != nil {
+		m.logger.MustDebug(err.Error())
+	}
 }
 
 func (m *Manager) routeEvent(event Event, clnt *Client) error {
-	if handler, ok := m.handlers[event.Type]; ok {
-		if err := handler(wsctx, clnt, event); err != nil {
+	if reg, ok := m.registration(event.Type); ok {
+		if err := reg.handler(wsctx, clnt, event); err != nil {
 			return err
 		}
 		return nil
 
 	} else {
-		return ErrEventNotSupported
+		return m.unknownEvent(event.Type)
 	}
 }
 
 func (m *Manager) AddClient(client *Client) {
 	m.Lock()
-	defer m.Unlock()
 	m.Clients[client.Connection] = client
+	m.Unlock()
+
+	m.pushPresence()
+	if !client.IsOwner {
+		m.NotifyOwners(EventVisitorArrived, dtos.ConnectionDto{Id: client.Id, ConnectedAt: client.ConnectedAt})
+	}
 }
 
 func (m *Manager) RemoveClient(client *Client) {
 	m.Lock()
-	defer m.Unlock()
-	defer delete(m.Clients, client.Connection)
-
-	if _, ok := m.Clients[client.Connection]; ok {
+	_, ok := m.Clients[client.Connection]
+	if ok {
 		client.Connection.Close()
+		delete(m.Clients, client.Connection)
+	}
+	m.Unlock()
+
+	// both the reader and writer remove the client on exit so only the first removal counts as a leave
+	if ok {
+		m.pushPresence()
+		go m.releaseHold(client)
 	}
 }
 
 func (m *Manager) SetClientPeriod(client *Client, period *models.Period) {
 	m.Lock()
-	defer m.Unlock()
-
-	if _, ok := m.Clients[client.Connection]; ok {
+	_, ok := m.Clients[client.Connection]
+	if ok {
 		m.Clients[client.Connection].SetPeriod(period)
 	}
+	m.Unlock()
+
+	if ok {
+		m.pushPresence()
+	}
 }
 
 func (m *Manager) BroadcastScheduleUpdate(prd *models.Period) {
-	result, err := controller.FetchSchedule(context.TODO(), prd.StartDate, prd.EndDate)
+	if prd == nil {
+		// the client never fetched a schedule so there is no period to refresh
+		return
+	}
 
+	// a snapshot replaces the client's schedule so it carries the versions without bumping them
+	versions, err := scheduleVersions(context.TODO(), prd)
 	if err != nil {
 		m.logger.MustDebug(err.Error())
+		return
 	}
 
-	resposne, ok := result.(*models.ScheduleResponse)
-
-	if !ok {
-		m.logger.MustDebug(fmt.Sprintf("failed to type assert [%T] as type [%T]", result, models.ScheduleResponse{}))
+	msg, err := scheduleEvent(context.TODO(), prd, versions)
+	if err != nil {
+		m.logger.MustDebug(err.Error())
+		return
 	}
 
-	rawResponse, err := json.Marshal(dtos.NewScheduleDto(resposne))
+	m.broadcast(context.TODO(), prd, msg, false)
+}
+
+// BroadcastHoldDelta sends a held or released slot to every client viewing any of it instead of rebuilding their
+// schedules, holds are not stored with the schedule so no day version is bumped
+func (m *Manager) BroadcastHoldDelta(evntType string, hold models.SlotHold) {
+	rawHold, err := json.Marshal(hold.Pending())
 	if err != nil {
-		m.logger.MustDebug(utils.NewJsonEncodeErr(resposne, err).Error())
+		m.logger.MustDebug(utils.NewJsonEncodeErr(hold, err).Error())
 		return
 	}
 
-	msg := Event{
-		Type:    EventBroadcastSchedule,
-		Payload: rawResponse,
+	m.broadcast(context.TODO(), &models.Period{StartDate: hold.Start, EndDate: hold.End}, Event{Type: evntType, Payload: rawHold}, true)
+}
+
+// BroadcastTaskDelta sends just the changed task to every client viewing the day it starts on instead of rebuilding the
+// whole schedule, the delta bumps that day's version so clients can spot a missed delta for any day they view
+func (m *Manager) BroadcastTaskDelta(evntType string, tsk models.Task) {
+	day := versionDay(tsk.StartTime)
+	version, err := nextScheduleVersion(context.TODO(), day)
+	if err != nil {
+		m.logger.MustDebug(err.Error())
+		return
 	}
 
-	for _, clnt := range m.Clients {
-		if utils.IsInRange(clnt.CurrentPeriod.StartDate, clnt.CurrentPeriod.EndDate, prd.StartDate, prd.EndDate) {
-			clnt.MessageQue <- msg
-		}
+	delta := dtos.NewTaskDeltaDto(day, version, tsk)
+	rawDelta, err := json.Marshal(delta)
+	if err != nil {
+		m.logger.MustDebug(utils.NewJsonEncodeErr(delta, err).Error())
+		return
 	}
+
+	m.broadcast(context.TODO(), dayPeriod(tsk.StartTime), Event{Type: evntType, Payload: rawDelta}, true)
 }
@@ -0,0 +1,302 @@
+package zypher
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	CorpusRandom     = "random"
+	CorpusDictionary = "dictionary"
+	CorpusBitFlip    = "bitflip"
+
+	DefaultAnalysisSize = 1000
+	MaxAnalysisSize     = 5000
+)
+
+var (
+	ErrUnknownCorpus = errors.New("unknown corpus, expected random, dictionary or bitflip")
+	ErrAnalysisSize  = fmt.Errorf("analysis size must be between 2 and %d", MaxAnalysisSize)
+)
+
+// AnalysisOptions picks the corpus Analyze hashes, a zero Size is DefaultAnalysisSize and a zero Seed is picked at random
+type AnalysisOptions struct {
+	Corpus string
+	Size   int
+	Seed   int64
+}
+
+func (o AnalysisOptions) Validate() error {
+	switch o.Corpus {
+	case "", CorpusRandom, CorpusDictionary, CorpusBitFlip:
+	default:
+		return ErrUnknownCorpus
+	}
+	if o.Size != 0 && (o.Size < 2 || o.Size > MaxAnalysisSize) {
+		return ErrAnalysisSize
+	}
+	return nil
+}
+
+// DigestStats describes the digests of a corpus. An ideal hash has no collisions, flips half of its output bits
+// when one input bit changes and spreads its output bytes evenly
+type DigestStats struct {
+	Collisions int
+	// the share of output bits that changed between an input and its one bit neighbor
+	AvalancheMean   float64
+	AvalancheStdDev float64
+	AvalancheMin    float64
+	AvalancheMax    float64
+	// how far the most lopsided output bit is from being set half the time
+	BitBias float64
+	// chi square statistic of the output byte counts against an even spread, a uniform hash lands close to its 255 degrees of freedom
+	ChiSquare float64
+}
+
+// Analysis compares the zypher digests of a corpus with plain sha512 digests of the same corpus
+type Analysis struct {
+	Corpus   string
+	Size     int
+	Seed     int64
+	Pairs    int
+	Zypher   DigestStats
+	Sha512   DigestStats
+	Duration time.Duration
+}
+
+// Analyze builds a corpus and reports collisions, avalanche and uniformity for the zypher and for plain sha512.
+// Every corpus holds one bit neighbors of its inputs for the avalanche figures:
+// random and dictionary pair each input with one neighbor, bitflip follows an input with every neighbor it has
+func Analyze(ctx context.Context, z Zypher, opts AnalysisOptions) (*Analysis, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Corpus == "" {
+		opts.Corpus = CorpusRandom
+	}
+	if opts.Size == 0 {
+		opts.Size = DefaultAnalysisSize
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+
+	// a single zyph can take seconds, with ctx on the zypher it stops between rounds rather than between inputs
+	WithContext(ctx)(&z)
+
+	start := time.Now()
+	inputs, pairs := buildCorpus(rand.New(rand.NewSource(opts.Seed)), opts.Corpus, opts.Size)
+
+	zyphed := make([][]byte, len(inputs))
+	plain := make([][]byte, len(inputs))
+	for i, input := range inputs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		digest, err := z.Zyph(input)
+		if err != nil {
+			return nil, err
+		}
+		if zyphed[i], err = hex.DecodeString(digest); err != nil {
+			// bcrypt digests are not hex, their bytes are compared as they are
+			zyphed[i] = []byte(digest)
+		}
+		sum := sha512.Sum512([]byte(input))
+		plain[i] = sum[:]
+	}
+
+	return &Analysis{
+		Corpus:   opts.Corpus,
+		Size:     len(inputs),
+		Seed:     opts.Seed,
+		Pairs:    len(pairs),
+		Zypher:   digestStats(zyphed, pairs),
+		Sha512:   digestStats(plain, pairs),
+		Duration: time.Since(start),
+	}, nil
+}
+
+var corpusWords = []string{
+	"password", "letmein", "dragon", "monkey", "shadow", "sunshine", "master", "welcome",
+	"summer", "winter", "football", "baseball", "princess", "charlie", "secret", "purple",
+	"orange", "coffee", "cookie", "tiger", "hunter", "silver", "ginger", "pepper",
+	"admin", "login", "guitar", "soccer", "flower", "banana", "rocket", "matrix",
+}
+
+// buildCorpus returns size distinct printable ascii inputs and the index pairs of inputs one bit apart
+func buildCorpus(rng *rand.Rand, corpus string, size int) ([]string, [][2]int) {
+	inputs := make([]string, 0, size)
+	pairs := make([][2]int, 0, size)
+	seen := make(map[string]bool, size)
+	add := func(input string) bool {
+		if seen[input] || len(inputs) == size {
+			return false
+		}
+		seen[input] = true
+		inputs = append(inputs, input)
+		return true
+	}
+
+	for len(inputs) < size {
+		var base string
+		if corpus == CorpusDictionary {
+			base = dictionaryInput(rng)
+		} else {
+			base = randomInput(rng)
+		}
+		if !add(base) {
+			continue
+		}
+		baseIndx := len(inputs) - 1
+
+		if corpus == CorpusBitFlip {
+			for _, neighbor := range bitNeighbors(base) {
+				if add(neighbor) {
+					pairs = append(pairs, [2]int{baseIndx, len(inputs) - 1})
+				}
+			}
+			continue
+		}
+
+		// a neighbor can already be in the corpus, a few tries finds one that is not
+		for try := 0; try < 8; try++ {
+			if add(flipBit(rng, base)) {
+				pairs = append(pairs, [2]int{baseIndx, len(inputs) - 1})
+				break
+			}
+		}
+	}
+	return inputs, pairs
+}
+
+func randomInput(rng *rand.Rand) string {
+	buf := make([]byte, 8+rng.Intn(17))
+	for i := range buf {
+		buf[i] = byte(' ' + rng.Intn('~'-' '+1))
+	}
+	return string(buf)
+}
+
+// dictionaryInput looks like a password a person picks, words with an optional capital, number and symbol
+func dictionaryInput(rng *rand.Rand) string {
+	var sb strings.Builder
+	word := corpusWords[rng.Intn(len(corpusWords))]
+	if rng.Intn(3) == 0 {
+		word = strings.ToUpper(word[:1]) + word[1:]
+	}
+	sb.WriteString(word)
+	if rng.Intn(2) == 0 {
+		sb.WriteString(corpusWords[rng.Intn(len(corpusWords))])
+	}
+	if rng.Intn(4) != 0 {
+		fmt.Fprintf(&sb, "%d", rng.Intn(10000))
+	}
+	if rng.Intn(4) == 0 {
+		sb.WriteByte("!@#$%&*?"[rng.Intn(8)])
+	}
+	return sb.String()
+}
+
+// flipBit flips one of the low five bits of a random byte, those keep a printable byte inside its block of 32
+// so at most one of them can land on DEL
+func flipBit(rng *rand.Rand, input string) string {
+	buf := []byte(input)
+	indx := rng.Intn(len(buf))
+	for _, bit := range rng.Perm(5) {
+		if flipped := buf[indx] ^ 1<<bit; isPrintableAscii(flipped) {
+			buf[indx] = flipped
+			break
+		}
+	}
+	return string(buf)
+}
+
+// bitNeighbors returns every printable input one bit away from input
+func bitNeighbors(input string) []string {
+	neighbors := make([]string, 0, len(input)*5)
+	buf := []byte(input)
+	for i, b := range buf {
+		for bit := 0; bit < 5; bit++ {
+			if flipped := b ^ 1<<bit; isPrintableAscii(flipped) {
+				buf[i] = flipped
+				neighbors = append(neighbors, string(buf))
+			}
+		}
+		buf[i] = b
+	}
+	return neighbors
+}
+
+func isPrintableAscii(b byte) bool {
+	return b >= ' ' && b <= '~'
+}
+
+func digestStats(digests [][]byte, pairs [][2]int) DigestStats {
+	var stats DigestStats
+
+	seen := make(map[string]bool, len(digests))
+	var byteCounts [256]int
+	var totalBytes int
+	bitCounts := make([]int, len(digests[0])*8)
+	for _, digest := range digests {
+		if seen[string(digest)] {
+			stats.Collisions++
+		}
+		seen[string(digest)] = true
+
+		for i, b := range digest {
+			byteCounts[b]++
+			for bit := 0; bit < 8 && i*8+bit < len(bitCounts); bit++ {
+				bitCounts[i*8+bit] += int(b >> bit & 1)
+			}
+		}
+		totalBytes += len(digest)
+	}
+
+	expected := float64(totalBytes) / 256
+	for _, count := range byteCounts {
+		diff := float64(count) - expected
+		stats.ChiSquare += diff * diff / expected
+	}
+	for _, count := range bitCounts {
+		stats.BitBias = max(stats.BitBias, math.Abs(float64(count)/float64(len(digests))-0.5))
+	}
+
+	if len(pairs) == 0 {
+		return stats
+	}
+	stats.AvalancheMin = 1
+	var sum, sumSq float64
+	for _, pair := range pairs {
+		changed := changedBits(digests[pair[0]], digests[pair[1]])
+		sum += changed
+		sumSq += changed * changed
+		stats.AvalancheMin = min(stats.AvalancheMin, changed)
+		stats.AvalancheMax = max(stats.AvalancheMax, changed)
+	}
+	n := float64(len(pairs))
+	stats.AvalancheMean = sum / n
+	stats.AvalancheStdDev = math.Sqrt(max(sumSq/n-stats.AvalancheMean*stats.AvalancheMean, 0))
+	return stats
+}
+
+// changedBits is the share of bits that differ between two digests, a length difference counts as changed bits
+func changedBits(a, b []byte) float64 {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	changed := (len(a) - len(b)) * 8
+	for i := range b {
+		changed += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return float64(changed) / float64(len(a)*8)
+}
@@ -0,0 +1,99 @@
+package zypher
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestAnalyze(t *testing.T) {
+	zy := NewZypher(WithHasher(hashers[SHA256]))
+	for _, corpus := range []string{CorpusRandom, CorpusDictionary, CorpusBitFlip} {
+		t.Run(corpus, func(t *testing.T) {
+			analysis, err := Analyze(context.Background(), *zy, AnalysisOptions{Corpus: corpus, Size: 400, Seed: 7})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if analysis.Size != 400 || analysis.Pairs == 0 {
+				t.Fatalf("expected 400 inputs with neighbor pairs, got %d inputs and %d pairs", analysis.Size, analysis.Pairs)
+			}
+			for name, stats := range map[string]DigestStats{"zypher": analysis.Zypher, "sha512": analysis.Sha512} {
+				if stats.Collisions != 0 {
+					t.Errorf("%s: expected no collisions, got %d", name, stats.Collisions)
+				}
+				if stats.AvalancheMean < 0.45 || stats.AvalancheMean > 0.55 {
+					t.Errorf("%s: expected about half the bits to change, got %f", name, stats.AvalancheMean)
+				}
+				if stats.BitBias > 0.15 {
+					t.Errorf("%s: expected no lopsided output bits, got a bias of %f", name, stats.BitBias)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeSeed(t *testing.T) {
+	zy := NewZypher(WithHasher(hashers[SHA256]))
+	opts := AnalysisOptions{Corpus: CorpusDictionary, Size: 50, Seed: 11}
+	first, err := Analyze(context.Background(), *zy, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Analyze(context.Background(), *zy, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Zypher != second.Zypher || first.Sha512 != second.Sha512 {
+		t.Fatal("expected the same seed to give the same analysis")
+	}
+}
+
+func TestAnalyzeStopsMidZyph(t *testing.T) {
+	// a single zyph with this many sha3-512 rounds takes a couple of seconds
+	zy := NewZypher(WithHasher(hashers[SHA3512]), WithHashIterCount(MaxIterCount))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := Analyze(ctx, *zy, AnalysisOptions{Size: 10}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to stop the analysis, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the analysis to stop inside its first zyph, it took %v", elapsed)
+	}
+}
+
+func TestBuildCorpusNeighbors(t *testing.T) {
+	inputs, pairs := buildCorpus(rand.New(rand.NewSource(3)), CorpusBitFlip, 100)
+	seen := map[string]bool{}
+	for _, input := range inputs {
+		if seen[input] {
+			t.Fatalf("duplicate input %q", input)
+		}
+		seen[input] = true
+	}
+	for _, pair := range pairs {
+		a, b := inputs[pair[0]], inputs[pair[1]]
+		if changedBits([]byte(a), []byte(b))*float64(len(a)*8) != 1 {
+			t.Fatalf("expected %q and %q to be one bit apart", a, b)
+		}
+	}
+}
+
+func TestAnalyzeOptions(t *testing.T) {
+	zy := NewZypher()
+	if _, err := Analyze(context.Background(), *zy, AnalysisOptions{Corpus: "words"}); !errors.Is(err, ErrUnknownCorpus) {
+		t.Errorf("expected ErrUnknownCorpus, got %v", err)
+	}
+	if _, err := Analyze(context.Background(), *zy, AnalysisOptions{Size: MaxAnalysisSize + 1}); !errors.Is(err, ErrAnalysisSize) {
+		t.Errorf("expected ErrAnalysisSize, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Analyze(ctx, *zy, AnalysisOptions{Size: 10}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a canceled analysis, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package zypher
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// legacyZyph is the goroutine per rune implementation Zyph replaced, kept to benchmark against
+func legacyZyph(z Zypher, arg string) string {
+	zypHashValidator.MatchString(arg)
+
+	result := make([]rune, len(arg))
+	var wg sync.WaitGroup
+	for i := 0; i < z.ShiftIterCount; i++ {
+		for indx, r := range arg {
+			wg.Add(1)
+			go func(indx int, r rune) {
+				defer wg.Done()
+				result[indx] = asciShift(r, z.Shift, z.Alternate && indx%2 != 0, z.IgnoreSpace)
+			}(indx, r)
+		}
+		wg.Wait()
+		arg = string(result)
+	}
+
+	for i := 0; i < z.HashIterCount; i++ {
+		sum := sha512.Sum512([]byte(arg))
+		arg = hex.EncodeToString(sum[:])
+	}
+	return arg
+}
+
+var benchInput = strings.Repeat("Zypher 42", 8)[:64]
+
+func TestLegacyZyphMatches(t *testing.T) {
+	zy := NewZypher(WithAlternate(true))
+	reslt, _ := zy.Zyph(benchInput)
+
+	if want := legacyZyph(*zy, benchInput); reslt != want {
+		t.Errorf("got %q, wanted the legacy digest %q", reslt, want)
+	}
+}
+
+func TestAppendZyphAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates on its own")
+	}
+
+	zy := NewZypher()
+	src := []byte(benchInput)
+	dst := make([]byte, 0, 256)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		dst, _ = zy.AppendZyph(dst[:0], src)
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations, wanted none", allocs)
+	}
+}
+
+func BenchmarkLegacyZyph(b *testing.B) {
+	zy := NewZypher()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		legacyZyph(*zy, benchInput)
+	}
+}
+
+func BenchmarkZyph(b *testing.B) {
+	zy := NewZypher()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		zy.Zyph(benchInput)
+	}
+}
+
+func BenchmarkAppendZyph(b *testing.B) {
+	zy := NewZypher()
+	src := []byte(benchInput)
+	dst := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst, _ = zy.AppendZyph(dst[:0], src)
+	}
+}
+
+func BenchmarkZyphUnicode(b *testing.B) {
+	zy := NewZypher()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		zy.Zyph("Grüße aus Köln 🔑")
+	}
+}
+
+func BenchmarkAsciZyph(b *testing.B) {
+	zy := NewZypher()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		zy.AsciZyph(benchInput)
+	}
+}
+
+func BenchmarkHexZyph(b *testing.B) {
+	zy := NewZypher(WithRestrictedHashShift(true))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		zy.HexZyph("9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08")
+	}
+}
@@ -0,0 +1,150 @@
+package zypher
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	calibrationInput = "a typical password"
+	// each measurement repeats until it has run this long so short zyphs are not lost in timer noise
+	calibrationSample = 20 * time.Millisecond
+)
+
+var ErrCalibrationTarget = errors.New("calibration target is shorter than a single zyph on this machine")
+
+// Calibration is what Calibrate recommends, Duration is how long a zyph with Params took on this machine
+type Calibration struct {
+	Params   Params
+	Duration time.Duration
+	// Capped is set when the target needs more hash iterations or higher kdf costs than a stored hash may have,
+	// Duration is then short of the target
+	Capped bool
+}
+
+// Calibrate benchmarks this machine and picks the hash iteration count that makes a zyph take about target.
+// ops set everything else, the shift iterations cost next to nothing so they are kept as given.
+// argon2id, scrypt and bcrypt run a single pass, for them the costs are scaled from the configured ones instead
+func Calibrate(target time.Duration, ops ...func(*Zypher)) (*Calibration, error) {
+	if target <= 0 {
+		return nil, ErrCalibrationTarget
+	}
+
+	zy := NewZypher(ops...)
+	salt, err := newSalt(zy.SaltLength)
+	if err != nil {
+		return nil, err
+	}
+	// Hash zyphs the salt along with the plaintext so the sample includes one
+	sample := []byte(salt + calibrationInput)
+
+	shiftOnly := *zy
+	shiftOnly.HashIterCount, shiftOnly.pepper = 0, nil
+	shiftCost := measure(func() {
+		shiftOnly.AppendZyph(nil, sample)
+	})
+	if shiftCost >= target {
+		return nil, ErrCalibrationTarget
+	}
+
+	capped := false
+	if zy.hasher().Iterative() {
+		hasher := zy.hasher()
+		digest, err := appendSum(hasher, nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		// later rounds hash the hex digest of the one before so that is the input to time
+		roundCost := measure(func() {
+			digest, _ = appendSum(hasher, digest, 0, nil)
+		})
+
+		count := int((target - shiftCost) / roundCost)
+		if count > MaxIterCount {
+			count, capped = MaxIterCount, true
+		}
+		zy.HashIterCount = max(count, 1)
+	} else {
+		zy.HashIterCount = 1
+		costed, ok := zy.hasher().(costedHasher)
+		if !ok {
+			return nil, fmt.Errorf("%v can not be calibrated", zy.hasher().Name())
+		}
+
+		var zyphErr error
+		passCost := measure(func() {
+			_, zyphErr = zy.AppendZyph(nil, sample)
+		})
+		if zyphErr != nil {
+			return nil, zyphErr
+		}
+
+		var costs KdfCosts
+		costs, capped = scaleCosts(costed.Name(), costed.costs(), float64(target-shiftCost)/float64(max(passCost-shiftCost, 1)))
+		hasher, err := HasherWithCosts(costed.Name(), costs)
+		if err != nil {
+			return nil, err
+		}
+		WithHasher(hasher)(zy)
+	}
+
+	var zyphErr error
+	duration := measure(func() {
+		_, zyphErr = zy.AppendZyph(nil, sample)
+	})
+	if zyphErr != nil {
+		return nil, zyphErr
+	}
+
+	return &Calibration{Params: zy.Params(), Duration: duration, Capped: capped}, nil
+}
+
+// measure runs f until calibrationSample has passed and returns the average time of a run
+func measure(f func()) time.Duration {
+	runs := 0
+	start := time.Now()
+	for time.Since(start) < calibrationSample || runs == 0 {
+		f()
+		runs++
+	}
+	return time.Since(start) / time.Duration(runs)
+}
+
+// scaleCosts multiplies the work of a kdf pass by factor as near as its costs allow, the time a pass takes grows
+// linearly with argon2id passes and memory and with scrypt n, and doubles with each bcrypt cost.
+// It reports whether the work was held back by the most a stored hash may have
+func scaleCosts(hasher string, costs KdfCosts, factor float64) (KdfCosts, bool) {
+	switch hasher {
+	case Argon2id:
+		// memory is the cost that makes guessing dear, passes are only added once it is at its most
+		work := float64(costs.Time*costs.Memory) * factor
+		costs.Memory, _ = clampCost(hasher, "m", int(work/float64(costs.Time)))
+		costs.Memory = max(costs.Memory, 8*costs.Threads)
+		var capped bool
+		costs.Time, capped = clampCost(hasher, "t", int(math.Round(work/float64(costs.Memory))))
+		return costs, capped
+	case Scrypt:
+		// n has to stay a power of two so it is rounded down to one
+		n, capped := clampCost(hasher, "n", int(float64(costs.N)*factor))
+		costs.N = 1 << (bits.Len(uint(n)) - 1)
+		return costs, capped
+	case Bcrypt:
+		var capped bool
+		costs.Cost, capped = clampCost(hasher, "c", costs.Cost+int(math.Round(math.Log2(factor))))
+		return costs, capped
+	}
+	return costs, false
+}
+
+// clampCost holds v to the bounds of a kdf cost and reports whether it was above them
+func clampCost(hasher, key string, v int) (int, bool) {
+	for _, field := range kdfCostFields[hasher] {
+		if field.key == key {
+			return min(max(v, field.min), field.max), v > field.max
+		}
+	}
+	return v, false
+}
@@ -0,0 +1,103 @@
+package zypher
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCalibrate(t *testing.T) {
+	target := 5 * time.Millisecond
+	cal, err := Calibrate(target, WithHasher(hashers[SHA256]), WithShift(7))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cal.Params.HashIterCount <= 1 || cal.Params.Shift != 7 || cal.Params.Hasher != SHA256 {
+		t.Errorf("got params %+v, wanted many sha256 rounds with the given shift", cal.Params)
+	}
+
+	// loose bounds so a busy machine does not fail the test
+	if cal.Duration <= 0 || cal.Duration > 10*target {
+		t.Errorf("got duration %v, wanted something near %v", cal.Duration, target)
+	}
+
+	zy, err := cal.Params.Zypher()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	encoded, _ := zy.Hash("hunter2")
+	if _, err := ParseEncoded(encoded); err != nil {
+		t.Errorf("got %v, wanted the calibrated params to be storable", err)
+	}
+}
+
+func TestCalibrateKdf(t *testing.T) {
+	cal, err := Calibrate(time.Millisecond, WithHasher(hashers[Argon2id]))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cal.Params.HashIterCount != 1 {
+		t.Errorf("got %v hash iterations, wanted a single kdf pass", cal.Params.HashIterCount)
+	}
+	if defaults := hashers[Argon2id].(costedHasher).costs(); cal.Params.Costs.Memory >= defaults.Memory {
+		t.Errorf("got %v KiB, wanted less memory than the default %v KiB for a 1ms target", cal.Params.Costs.Memory, defaults.Memory)
+	}
+
+	zy, err := cal.Params.Zypher()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	encoded, _ := zy.Hash("hunter2")
+	if parsed, err := ParseEncoded(encoded); err != nil || parsed.Params.Costs != cal.Params.Costs {
+		t.Errorf("got %+v %v, wanted the calibrated costs %+v stored", parsed, err, cal.Params.Costs)
+	}
+}
+
+func TestScaleCosts(t *testing.T) {
+	tests := map[string]struct {
+		hasher string
+		costs  KdfCosts
+		factor float64
+		want   KdfCosts
+		capped bool
+	}{
+		"argon2id memory":     {Argon2id, KdfCosts{Time: 2, Memory: 19456, Threads: 1}, 2, KdfCosts{Time: 2, Memory: 38912, Threads: 1}, false},
+		"argon2id passes":     {Argon2id, KdfCosts{Time: 2, Memory: 1 << 17, Threads: 1}, 4, KdfCosts{Time: 4, Memory: 1 << 18, Threads: 1}, false},
+		"argon2id capped":     {Argon2id, KdfCosts{Time: 2, Memory: 1 << 18, Threads: 1}, 100, KdfCosts{Time: 16, Memory: 1 << 18, Threads: 1}, true},
+		"argon2id least":      {Argon2id, KdfCosts{Time: 2, Memory: 64, Threads: 2}, 0.01, KdfCosts{Time: 1, Memory: 16, Threads: 2}, false},
+		"scrypt power of two": {Scrypt, KdfCosts{N: 1 << 15}, 3, KdfCosts{N: 1 << 16}, false},
+		"scrypt capped":       {Scrypt, KdfCosts{N: 1 << 15}, 64, KdfCosts{N: 1 << 18}, true},
+		"bcrypt up":           {Bcrypt, KdfCosts{Cost: 10}, 4, KdfCosts{Cost: 12}, false},
+		"bcrypt down":         {Bcrypt, KdfCosts{Cost: 10}, 0.25, KdfCosts{Cost: 8}, false},
+		"bcrypt capped":       {Bcrypt, KdfCosts{Cost: 10}, 1024, KdfCosts{Cost: 16}, true},
+	}
+
+	for name, tt := range tests {
+		got, capped := scaleCosts(tt.hasher, tt.costs, tt.factor)
+		if got != tt.want || capped != tt.capped {
+			t.Errorf("%v: got %+v capped %v, wanted %+v capped %v", name, got, capped, tt.want, tt.capped)
+		}
+	}
+}
+
+func TestCalibrateCapped(t *testing.T) {
+	cal, err := Calibrate(time.Minute, WithHasher(hashers[SHA256]))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !cal.Capped || cal.Params.HashIterCount != MaxIterCount {
+		t.Errorf("got capped %v with %v hash iterations, wanted capped at %v", cal.Capped, cal.Params.HashIterCount, MaxIterCount)
+	}
+	if cal.Duration >= time.Minute {
+		t.Errorf("got duration %v, wanted it short of the target", cal.Duration)
+	}
+}
+
+func TestCalibrateInvalidTarget(t *testing.T) {
+	if _, err := Calibrate(0); !errors.Is(err, ErrCalibrationTarget) {
+		t.Errorf("got %v, wanted ErrCalibrationTarget", err)
+	}
+}
@@ -0,0 +1,303 @@
+package zypher
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	encodingId      = "zypher"
+	encodingVersion = 1
+	// MaxIterCount bounds the params of a stored hash so a tampered one can not pin the cpu
+	MaxIterCount = 1 << 20
+)
+
+var (
+	ErrInvalidEncoding    = errors.New("invalid zypher hash encoding")
+	ErrUnsupportedVersion = errors.New("unsupported zypher hash version")
+)
+
+// Params are the settings that change a zypher digest, they are stored with the hash so it can be verified later
+type Params struct {
+	Shift             int
+	ShiftIterCount    int
+	HashIterCount     int
+	Alternate         bool
+	IgnoreSpace       bool
+	RestrictHashShift bool
+	Hasher            string
+	// Costs are the work factors of a kdf Hasher, zero for the digests
+	Costs KdfCosts
+}
+
+func (z Zypher) Params() Params {
+	return Params{
+		Shift:             z.Shift,
+		ShiftIterCount:    z.ShiftIterCount,
+		HashIterCount:     z.HashIterCount,
+		Alternate:         z.Alternate,
+		IgnoreSpace:       z.IgnoreSpace,
+		RestrictHashShift: z.RestrictHashShift,
+		Hasher:            z.hasher().Name(),
+		Costs:             hasherCosts(z.hasher()),
+	}
+}
+
+// Zypher builds a zypher that produces digests with these params, ops like WithPepper fill in what the params leave out
+func (p Params) Zypher(ops ...func(*Zypher)) (*Zypher, error) {
+	hasher, err := HasherWithCosts(p.Hasher, p.Costs)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewZypher(append(ops,
+		WithHasher(hasher),
+		WithShift(p.Shift),
+		WithShiftIterCount(p.ShiftIterCount),
+		WithHashIterCount(p.HashIterCount),
+		WithAlternate(p.Alternate),
+		WithIgnoreSpace(p.IgnoreSpace),
+		WithRestrictedHashShift(p.RestrictHashShift),
+	)...), nil
+}
+
+func (p Params) String() string {
+	hasher := p.Hasher
+	if hasher == "" {
+		hasher = SHA512
+	}
+	encoded := fmt.Sprintf("s=%d,si=%d,hi=%d,a=%d,i=%d,r=%d,h=%s", p.Shift, p.ShiftIterCount, p.HashIterCount, btoi(p.Alternate), btoi(p.IgnoreSpace), btoi(p.RestrictHashShift), hasher)
+	costs := p.Costs
+	for _, field := range kdfCostFields[hasher] {
+		if v := *field.value(&costs); v != 0 {
+			encoded += fmt.Sprintf(",%s=%d", field.key, v)
+		}
+	}
+	return encoded
+}
+
+// EncodedHash is a digest along with everything needed to reproduce it, formatted as
+// $zypher$v=1$s=3,si=3,hi=3,a=0,i=0,r=0,h=sha512$<salt>$<hash>. The kdf hashers add their costs,
+// h=argon2id,t=2,m=19456,p=1 or h=scrypt,n=32768 or h=bcrypt,c=10
+type EncodedHash struct {
+	Version int
+	Params  Params
+	Salt    string
+	Hash    string
+}
+
+func (e EncodedHash) String() string {
+	return fmt.Sprintf("$%s$v=%d$%s$%s$%s", encodingId, e.Version, e.Params, e.Salt, e.Hash)
+}
+
+// Hash zyphs the plaintext with a fresh random salt and returns it in the encoded format,
+// use Zyph when the same input has to produce the same digest
+func (z Zypher) Hash(plaintext string) (string, error) {
+	salt, err := newSalt(z.SaltLength)
+	if err != nil {
+		return "", err
+	}
+
+	// the salt goes in front so it is carried through every shift round
+	digest, err := z.Zyph(salt + plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := EncodedHash{
+		Version: encodingVersion,
+		Params:  z.Params(),
+		Salt:    salt,
+		Hash:    digest,
+	}
+	return encoded.String(), nil
+}
+
+// Verify reports whether plaintext produces the encoded hash using the params stored in it,
+// a peppered hash needs the same WithPepper op it was created with
+func Verify(encoded, plaintext string, ops ...func(*Zypher)) (bool, error) {
+	parsed, err := ParseEncoded(encoded)
+	if err != nil {
+		return false, err
+	}
+	return parsed.verify(plaintext, ops...)
+}
+
+func (e EncodedHash) verify(plaintext string, ops ...func(*Zypher)) (bool, error) {
+	zy, err := e.Params.Zypher(ops...)
+	if err != nil {
+		return false, err
+	}
+	return zy.verifyDigest(e.Salt+plaintext, e.Hash)
+}
+
+func ParseEncoded(encoded string) (*EncodedHash, error) {
+	// a leading $ means the first field is always empty
+	fields := strings.Split(encoded, "$")
+	if len(fields) != 6 || fields[0] != "" || fields[1] != encodingId {
+		return nil, fmt.Errorf("%w: expected $%s$v=<version>$<params>$<salt>$<hash>", ErrInvalidEncoding, encodingId)
+	}
+
+	version, err := parseVersion(fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := parseParams(fields[3])
+	if err != nil {
+		return nil, err
+	}
+
+	if !isSalt(fields[4]) {
+		return nil, fmt.Errorf("%w: invalid salt", ErrInvalidEncoding)
+	}
+
+	if fields[5] == "" {
+		return nil, fmt.Errorf("%w: missing hash", ErrInvalidEncoding)
+	}
+
+	return &EncodedHash{
+		Version: version,
+		Params:  *params,
+		Salt:    fields[4],
+		Hash:    fields[5],
+	}, nil
+}
+
+func parseVersion(field string) (int, error) {
+	raw, found := strings.CutPrefix(field, "v=")
+	if !found {
+		return 0, fmt.Errorf("%w: missing version", ErrInvalidEncoding)
+	}
+
+	version, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid version %q", ErrInvalidEncoding, raw)
+	}
+
+	if version != encodingVersion {
+		return 0, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	return version, nil
+}
+
+func parseParams(field string) (*Params, error) {
+	values := make(map[string]int)
+	// hashes written before h= existed were all sha512
+	hasher := SHA512
+	hasHasher := false
+	for _, pair := range strings.Split(field, ",") {
+		key, raw, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("%w: malformed param %q", ErrInvalidEncoding, pair)
+		}
+
+		if _, ok := values[key]; ok || (key == "h" && hasHasher) {
+			return nil, fmt.Errorf("%w: duplicate param %q", ErrInvalidEncoding, key)
+		}
+
+		if key == "h" {
+			h, err := LookupHasher(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+			}
+			hasher, hasHasher = h.Name(), true
+			continue
+		}
+
+		val, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value for %q", ErrInvalidEncoding, key)
+		}
+		values[key] = val
+	}
+
+	for _, key := range []string{"s", "si", "hi", "a", "i", "r"} {
+		if _, ok := values[key]; !ok {
+			return nil, fmt.Errorf("%w: missing param %q", ErrInvalidEncoding, key)
+		}
+	}
+
+	// hashes written before the costs were encoded were made with the defaults
+	var costs KdfCosts
+	for _, cost := range kdfCostFields[hasher] {
+		if v, ok := values[cost.key]; ok {
+			// a zero cost means the default to HasherWithCosts, written out it is just invalid
+			if v < cost.min {
+				return nil, fmt.Errorf("%w: %v %q must be at least %d, got %d", ErrInvalidEncoding, hasher, cost.key, cost.min, v)
+			}
+			*cost.value(&costs) = v
+			delete(values, cost.key)
+		}
+	}
+	hshr, err := HasherWithCosts(hasher, costs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	if len(values) != 6 {
+		return nil, fmt.Errorf("%w: unknown params in %q", ErrInvalidEncoding, field)
+	}
+
+	for _, key := range []string{"si", "hi"} {
+		if values[key] < 0 || values[key] > MaxIterCount {
+			return nil, fmt.Errorf("%w: %q must be between 0 and %d", ErrInvalidEncoding, key, MaxIterCount)
+		}
+	}
+	// a negative shift shifts the other way
+	if values["s"] < -MaxIterCount || values["s"] > MaxIterCount {
+		return nil, fmt.Errorf("%w: %q must be between %d and %d", ErrInvalidEncoding, "s", -MaxIterCount, MaxIterCount)
+	}
+
+	flags := make(map[string]bool)
+	for _, key := range []string{"a", "i", "r"} {
+		switch values[key] {
+		case 0:
+			flags[key] = false
+		case 1:
+			flags[key] = true
+		default:
+			return nil, fmt.Errorf("%w: %q must be 0 or 1", ErrInvalidEncoding, key)
+		}
+	}
+
+	return &Params{
+		Shift:             values["s"],
+		ShiftIterCount:    values["si"],
+		HashIterCount:     values["hi"],
+		Alternate:         flags["a"],
+		IgnoreSpace:       flags["i"],
+		RestrictHashShift: flags["r"],
+		Hasher:            hasher,
+		Costs:             hasherCosts(hshr),
+	}, nil
+}
+
+// newSalt returns length random bytes as unpadded url safe base64, every character in it passes the Zyph input check
+func newSalt(length int) (string, error) {
+	if length <= 0 {
+		return "", nil
+	}
+
+	salt := make([]byte, length)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("could not generate salt:: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(salt), nil
+}
+
+func isSalt(salt string) bool {
+	_, err := base64.RawURLEncoding.DecodeString(salt)
+	return err == nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,231 @@
+package zypher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashFormat(t *testing.T) {
+	zy := NewZypher(WithShift(4), WithShiftIterCount(2), WithHashIterCount(1), WithAlternate(true), WithSaltLength(0))
+	encoded, err := zy.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	digest, _ := zy.Zyph("hunter2")
+	want := "$zypher$v=1$s=4,si=2,hi=1,a=1,i=0,r=0,h=sha512$$" + digest
+
+	if encoded != want {
+		t.Errorf("got %q, wanted %q", encoded, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	zy := NewZypher(WithShift(5), WithIgnoreSpace(true))
+	encoded, err := zy.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	ok, err := Verify(encoded, "correct horse")
+	if err != nil || !ok {
+		t.Errorf("got %v %v, wanted match for the original plaintext", ok, err)
+	}
+
+	ok, err = Verify(encoded, "correct horsf")
+	if err != nil || ok {
+		t.Errorf("got %v %v, wanted no match for a different plaintext", ok, err)
+	}
+}
+
+func TestParseEncoded(t *testing.T) {
+	parsed, err := ParseEncoded("$zypher$v=1$s=-2,si=4,hi=5,a=0,i=1,r=1$$abc123")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// no h param means the hash predates pluggable hashers
+	want := Params{Shift: -2, ShiftIterCount: 4, HashIterCount: 5, IgnoreSpace: true, RestrictHashShift: true, Hasher: SHA512}
+	if parsed.Params != want {
+		t.Errorf("got params %+v, wanted %+v", parsed.Params, want)
+	}
+
+	if parsed.Hash != "abc123" {
+		t.Errorf("got hash %q, wanted %q", parsed.Hash, "abc123")
+	}
+}
+
+func TestParseEncodedInvalid(t *testing.T) {
+	tests := map[string]string{
+		"bare hex":        "abc123",
+		"wrong id":        "$argon2$v=1$s=3,si=3,hi=3,a=0,i=0,r=0$$abc",
+		"missing param":   "$zypher$v=1$s=3,si=3,hi=3,a=0,i=0$$abc",
+		"unknown param":   "$zypher$v=1$s=3,si=3,hi=3,a=0,i=0,r=0,x=1$$abc",
+		"bad flag":        "$zypher$v=1$s=3,si=3,hi=3,a=2,i=0,r=0$$abc",
+		"negative iters":  "$zypher$v=1$s=3,si=-1,hi=3,a=0,i=0,r=0$$abc",
+		"huge iters":      "$zypher$v=1$s=3,si=3,hi=99999999,a=0,i=0,r=0$$abc",
+		"huge shift":      "$zypher$v=1$s=99999999,si=3,hi=3,a=0,i=0,r=0$$abc",
+		"huge back shift": "$zypher$v=1$s=-99999999,si=3,hi=3,a=0,i=0,r=0$$abc",
+		"missing hash":    "$zypher$v=1$s=3,si=3,hi=3,a=0,i=0,r=0$$",
+		"too many fields": "$zypher$v=1$s=3,si=3,hi=3,a=0,i=0,r=0$$abc$def",
+		"unknown hasher":  "$zypher$v=1$s=3,si=3,hi=3,a=0,i=0,r=0,h=md5$$abc",
+		"digest cost":     "$zypher$v=1$s=3,si=3,hi=3,a=0,i=0,r=0,h=sha512,c=10$$abc",
+		"huge memory":     "$zypher$v=1$s=3,si=3,hi=1,a=0,i=0,r=0,h=argon2id,t=2,m=99999999,p=1$$abc",
+		"zero time":       "$zypher$v=1$s=3,si=3,hi=1,a=0,i=0,r=0,h=argon2id,t=0,m=19456,p=1$$abc",
+		"scrypt n":        "$zypher$v=1$s=3,si=3,hi=1,a=0,i=0,r=0,h=scrypt,n=30000$$abc",
+		"bcrypt cost":     "$zypher$v=1$s=3,si=3,hi=1,a=0,i=0,r=0,h=bcrypt,c=31$$abc",
+		"duplicate cost":  "$zypher$v=1$s=3,si=3,hi=1,a=0,i=0,r=0,h=scrypt,n=1024,n=2048$$abc",
+	}
+
+	for name, encoded := range tests {
+		if _, err := ParseEncoded(encoded); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("%v: got %v, wanted ErrInvalidEncoding", name, err)
+		}
+	}
+
+	_, err := ParseEncoded("$zypher$v=9$s=3,si=3,hi=3,a=0,i=0,r=0$$abc")
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("got %v, wanted ErrUnsupportedVersion", err)
+	}
+}
+
+func TestEncodedRoundTrip(t *testing.T) {
+	zy := NewZypher(WithShift(7), WithShiftIterCount(1), WithRestrictedHashShift(true))
+	encoded, _ := zy.Hash("round trip")
+
+	parsed, err := ParseEncoded(encoded)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if parsed.String() != encoded {
+		t.Errorf("got %q, wanted %q", parsed.String(), encoded)
+	}
+
+	if !strings.HasPrefix(encoded, "$zypher$v=1$") {
+		t.Errorf("got %q, wanted the zypher prefix", encoded)
+	}
+}
+
+func TestHashSalted(t *testing.T) {
+	zy := NewZypher()
+	first, _ := zy.Hash("hunter2")
+	second, _ := zy.Hash("hunter2")
+
+	if first == second {
+		t.Errorf("got the same hash twice %q, wanted a fresh salt each time", first)
+	}
+
+	for _, encoded := range []string{first, second} {
+		parsed, err := ParseEncoded(encoded)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		// 16 bytes of unpadded base64
+		if len(parsed.Salt) != 22 {
+			t.Errorf("got salt %q, wanted 22 characters", parsed.Salt)
+		}
+
+		if ok, err := Verify(encoded, "hunter2"); err != nil || !ok {
+			t.Errorf("got %v %v, wanted %q to verify", ok, err, encoded)
+		}
+	}
+}
+
+func TestHashPepper(t *testing.T) {
+	zy := NewZypher(WithPepper("server secret"))
+	encoded, err := zy.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if strings.Contains(encoded, "server secret") {
+		t.Errorf("got %q, wanted the pepper left out of the encoded hash", encoded)
+	}
+
+	if ok, _ := Verify(encoded, "hunter2"); ok {
+		t.Errorf("got a match without the pepper, wanted no match")
+	}
+
+	if ok, err := Verify(encoded, "hunter2", WithPepper("server secret")); err != nil || !ok {
+		t.Errorf("got %v %v, wanted a match with the pepper", ok, err)
+	}
+
+	if ok, _ := Verify(encoded, "hunter2", WithPepper("other secret")); ok {
+		t.Errorf("got a match with the wrong pepper, wanted no match")
+	}
+}
+
+func TestPepperNeedsHashRound(t *testing.T) {
+	zy := NewZypher(WithPepper("server secret"), WithHashIterCount(0))
+	if _, err := zy.Hash("hunter2"); !errors.Is(err, ErrPepperWithoutHash) {
+		t.Errorf("got %v, wanted ErrPepperWithoutHash", err)
+	}
+}
+
+func TestZyphStaysDeterministic(t *testing.T) {
+	zy := NewZypher()
+	first, _ := zy.Zyph("127.0.0.1")
+	second, _ := zy.Zyph("127.0.0.1")
+
+	if first != second {
+		t.Errorf("got %q and %q, wanted Zyph to be deterministic", first, second)
+	}
+}
+
+func TestKdfCostsEncoded(t *testing.T) {
+	tests := map[string]struct {
+		hasher string
+		costs  KdfCosts
+		params string
+	}{
+		"argon2id defaults": {Argon2id, KdfCosts{}, "h=argon2id,t=2,m=19456,p=1"},
+		"argon2id tuned":    {Argon2id, KdfCosts{Time: 1, Memory: 64}, "h=argon2id,t=1,m=64,p=1"},
+		"scrypt tuned":      {Scrypt, KdfCosts{N: 1 << 10}, "h=scrypt,n=1024"},
+		"bcrypt tuned":      {Bcrypt, KdfCosts{Cost: bcrypt.MinCost}, "h=bcrypt,c=4"},
+		"digest":            {SHA256, KdfCosts{Time: 5}, "h=sha256"},
+	}
+
+	for name, tt := range tests {
+		hshr, err := HasherWithCosts(tt.hasher, tt.costs)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", name, err)
+		}
+		zy := NewZypher(WithHasher(hshr), WithHashIterCount(1))
+		encoded, err := zy.Hash("hunter2")
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", name, err)
+		}
+
+		parsed, err := ParseEncoded(encoded)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", name, err)
+		}
+		if !strings.HasSuffix(parsed.Params.String(), tt.params) {
+			t.Errorf("%v: got params %q, wanted them to end with %q", name, parsed.Params, tt.params)
+		}
+		if parsed.Params != zy.Params() {
+			t.Errorf("%v: got %+v, wanted %+v", name, parsed.Params, zy.Params())
+		}
+
+		// the costs come from the hash so verifying needs nothing but the plaintext
+		if ok, err := Verify(encoded, "hunter2"); err != nil || !ok {
+			t.Errorf("%v: got %v %v, wanted a match", name, ok, err)
+		}
+	}
+}
+
+func TestKdfCostsDefaultForOldHashes(t *testing.T) {
+	// written before the costs were encoded, the defaults were the only costs then
+	parsed, err := ParseEncoded("$zypher$v=1$s=3,si=3,hi=1,a=0,i=0,r=0,h=scrypt$$abc")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := (KdfCosts{N: 1 << 15}); parsed.Params.Costs != want {
+		t.Errorf("got costs %+v, wanted %+v", parsed.Params.Costs, want)
+	}
+}
@@ -0,0 +1,329 @@
+package zypher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	SHA256   = "sha256"
+	SHA512   = "sha512"
+	SHA3512  = "sha3-512"
+	BLAKE2b  = "blake2b"
+	Argon2id = "argon2id"
+	Scrypt   = "scrypt"
+	Bcrypt   = "bcrypt"
+)
+
+// the shifted input already carries the hash salt so the kdfs use a fixed one to stay reproducible
+var kdfSalt = []byte("zypher-kdf-v1")
+
+var ErrUnknownHasher = errors.New("unknown zypher hasher")
+
+// Hasher is the digest used for the hash rounds of a zypher
+type Hasher interface {
+	Name() string
+	// Iterative hashers run once per hash iteration, the memory hard ones are slow on purpose and run a single pass
+	Iterative() bool
+	// Sum hashes data, key is the pepper and is only passed on the first round
+	Sum(data, key []byte) ([]byte, error)
+	// Verify reports whether sum came from data, hashers with their own random salt can not be checked by hashing again
+	Verify(data, key, sum []byte) (bool, error)
+}
+
+var hashers = map[string]Hasher{
+	SHA256:   newDigestHasher(SHA256, sha256.New),
+	SHA512:   newDigestHasher(SHA512, sha512.New),
+	SHA3512:  newDigestHasher(SHA3512, sha3.New512),
+	BLAKE2b:  newDigestHasher(BLAKE2b, newBlake2b),
+	Argon2id: argon2Hasher{time: 2, memory: 19 * 1024, threads: 1, keyLen: 64},
+	Scrypt:   scryptHasher{n: 1 << 15, r: 8, p: 1, keyLen: 64},
+	Bcrypt:   bcryptHasher{cost: bcrypt.DefaultCost},
+}
+
+var ErrKdfCost = errors.New("invalid kdf cost")
+
+// KdfCosts are the work factors of the kdf hashers, each hasher only reads its own and a zero keeps the default
+type KdfCosts struct {
+	Time    int // argon2id passes over its memory
+	Memory  int // argon2id memory in KiB
+	Threads int // argon2id lanes
+	N       int // scrypt cost, a power of two
+	Cost    int // bcrypt cost, the log2 of its rounds
+}
+
+// costField is one kdf cost as it is written into an encoded hash. Stored hashes are parsed before anything
+// runs so the bounds keep a tampered one from taking more than a few seconds or 256MiB
+type costField struct {
+	key      string
+	value    func(*KdfCosts) *int
+	min, max int
+	// work is set for the costs that make the hasher slower, Policy.Weaker compares those
+	work bool
+}
+
+var kdfCostFields = map[string][]costField{
+	Argon2id: {
+		{key: "t", value: func(c *KdfCosts) *int { return &c.Time }, min: 1, max: 16, work: true},
+		{key: "m", value: func(c *KdfCosts) *int { return &c.Memory }, min: 8, max: 1 << 18, work: true},
+		{key: "p", value: func(c *KdfCosts) *int { return &c.Threads }, min: 1, max: 16},
+	},
+	Scrypt: {
+		{key: "n", value: func(c *KdfCosts) *int { return &c.N }, min: 2, max: 1 << 18, work: true},
+	},
+	Bcrypt: {
+		{key: "c", value: func(c *KdfCosts) *int { return &c.Cost }, min: bcrypt.MinCost, max: 16, work: true},
+	},
+}
+
+// costedHasher is a kdf whose costs can be tuned, its costs are written into encoded hashes
+type costedHasher interface {
+	Hasher
+	costs() KdfCosts
+	withCosts(KdfCosts) Hasher
+}
+
+// HasherWithCosts looks up name like LookupHasher and gives a kdf the non zero costs, digest hashers ignore them
+func HasherWithCosts(name string, costs KdfCosts) (Hasher, error) {
+	h, err := LookupHasher(name)
+	if err != nil {
+		return nil, err
+	}
+
+	costed, ok := h.(costedHasher)
+	if !ok {
+		return h, nil
+	}
+
+	merged := costed.costs()
+	for _, field := range kdfCostFields[h.Name()] {
+		if v := *field.value(&costs); v != 0 {
+			*field.value(&merged) = v
+		}
+		if v := *field.value(&merged); v < field.min || v > field.max {
+			return nil, fmt.Errorf("%w: %v %q must be between %d and %d, got %d", ErrKdfCost, h.Name(), field.key, field.min, field.max, v)
+		}
+	}
+	if h.Name() == Scrypt && merged.N&(merged.N-1) != 0 {
+		return nil, fmt.Errorf("%w: scrypt %q must be a power of two, got %d", ErrKdfCost, "n", merged.N)
+	}
+	if h.Name() == Argon2id && merged.Memory < 8*merged.Threads {
+		return nil, fmt.Errorf("%w: argon2id needs at least 8KiB of memory per thread", ErrKdfCost)
+	}
+	return costed.withCosts(merged), nil
+}
+
+// hasherCosts are the costs of h, zero for the digest hashers
+func hasherCosts(h Hasher) KdfCosts {
+	if costed, ok := h.(costedHasher); ok {
+		return costed.costs()
+	}
+	return KdfCosts{}
+}
+
+// LookupHasher returns the hasher registered under name, an empty name is the default sha512
+func LookupHasher(name string) (Hasher, error) {
+	if name == "" {
+		name = SHA512
+	}
+
+	h, ok := hashers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q, expected one of %v", ErrUnknownHasher, name, strings.Join(HasherNames(), ", "))
+	}
+	return h, nil
+}
+
+func HasherNames() []string {
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type digestHasher struct {
+	name string
+	new  func() hash.Hash
+	// unkeyed hash states are reused so the hash rounds do not allocate
+	pool *sync.Pool
+}
+
+func newDigestHasher(name string, new func() hash.Hash) digestHasher {
+	return digestHasher{
+		name: name,
+		new:  new,
+		pool: &sync.Pool{New: func() any {
+			return &digestState{hash: new()}
+		}},
+	}
+}
+
+type digestState struct {
+	hash hash.Hash
+	sum  [64]byte
+}
+
+func (d digestHasher) Name() string    { return d.name }
+func (d digestHasher) Iterative() bool { return true }
+
+func (d digestHasher) Sum(data, key []byte) ([]byte, error) {
+	hsh := d.new()
+	if len(key) > 0 {
+		hsh = hmac.New(d.new, key)
+	}
+	hsh.Write(data)
+	return hsh.Sum(nil), nil
+}
+
+func (d digestHasher) Verify(data, key, sum []byte) (bool, error) {
+	return verifyBySum(d, data, key, sum)
+}
+
+// appendSum replaces dst[start:] with the hex digest of dst[start:]
+func (d digestHasher) appendSum(dst []byte, start int) []byte {
+	state := d.pool.Get().(*digestState)
+	defer d.pool.Put(state)
+
+	state.hash.Reset()
+	state.hash.Write(dst[start:])
+	return hex.AppendEncode(dst[:start], state.hash.Sum(state.sum[:0]))
+}
+
+func newBlake2b() hash.Hash {
+	// only errors for a key longer than 64 bytes and no key is passed
+	hsh, _ := blake2b.New512(nil)
+	return hsh
+}
+
+type argon2Hasher struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	keyLen  uint32
+}
+
+func (a argon2Hasher) Name() string    { return Argon2id }
+func (a argon2Hasher) Iterative() bool { return false }
+
+func (a argon2Hasher) Sum(data, key []byte) ([]byte, error) {
+	return argon2.IDKey(withPepper(data, key), kdfSalt, a.time, a.memory, a.threads, a.keyLen), nil
+}
+
+func (a argon2Hasher) Verify(data, key, sum []byte) (bool, error) {
+	return verifyBySum(a, data, key, sum)
+}
+
+func (a argon2Hasher) costs() KdfCosts {
+	return KdfCosts{Time: int(a.time), Memory: int(a.memory), Threads: int(a.threads)}
+}
+
+func (a argon2Hasher) withCosts(c KdfCosts) Hasher {
+	a.time, a.memory, a.threads = uint32(c.Time), uint32(c.Memory), uint8(c.Threads)
+	return a
+}
+
+type scryptHasher struct {
+	n, r, p, keyLen int
+}
+
+func (s scryptHasher) Name() string    { return Scrypt }
+func (s scryptHasher) Iterative() bool { return false }
+
+func (s scryptHasher) Sum(data, key []byte) ([]byte, error) {
+	return scrypt.Key(withPepper(data, key), kdfSalt, s.n, s.r, s.p, s.keyLen)
+}
+
+func (s scryptHasher) Verify(data, key, sum []byte) (bool, error) {
+	return verifyBySum(s, data, key, sum)
+}
+
+func (s scryptHasher) costs() KdfCosts { return KdfCosts{N: s.n} }
+
+func (s scryptHasher) withCosts(c KdfCosts) Hasher {
+	s.n = c.N
+	return s
+}
+
+// bcryptHasher salts every sum itself so its digests differ on each call and have to be checked with Verify
+type bcryptHasher struct {
+	cost int
+}
+
+func (b bcryptHasher) Name() string    { return Bcrypt }
+func (b bcryptHasher) Iterative() bool { return false }
+
+func (b bcryptHasher) Sum(data, key []byte) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(bcryptInput(data, key), b.cost)
+}
+
+func (b bcryptHasher) Verify(data, key, sum []byte) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(sum, bcryptInput(data, key))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (b bcryptHasher) costs() KdfCosts { return KdfCosts{Cost: b.cost} }
+
+func (b bcryptHasher) withCosts(c KdfCosts) Hasher {
+	b.cost = c.Cost
+	return b
+}
+
+// bcryptInput pre hashes because bcrypt ignores everything past 72 bytes and a salted shifted input is often longer
+func bcryptInput(data, key []byte) []byte {
+	sum := sha512.Sum512(withPepper(data, key))
+	return []byte(fmt.Sprintf("%x", sum[:]))[:72]
+}
+
+// withPepper keys the input for the hashers that have no keyed mode of their own
+func withPepper(data, key []byte) []byte {
+	if len(key) == 0 {
+		return data
+	}
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// appendSum hashes dst[start:] with h and puts the hex digest in its place, the digest hashers skip the allocations when there is no key
+func appendSum(h Hasher, dst []byte, start int, key []byte) ([]byte, error) {
+	if d, ok := h.(digestHasher); ok && len(key) == 0 {
+		return d.appendSum(dst, start), nil
+	}
+
+	sum, err := h.Sum(dst[start:], key)
+	if err != nil {
+		return dst, err
+	}
+	return hex.AppendEncode(dst[:start], sum), nil
+}
+
+func verifyBySum(h Hasher, data, key, sum []byte) (bool, error) {
+	expected, err := h.Sum(data, key)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(expected, sum) == 1, nil
+}
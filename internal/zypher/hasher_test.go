@@ -0,0 +1,112 @@
+package zypher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHashersVerify(t *testing.T) {
+	for _, name := range HasherNames() {
+		hasher, err := LookupHasher(name)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		zy := NewZypher(WithHasher(hasher), WithHashIterCount(2))
+		encoded, err := zy.Hash("hunter2")
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", name, err)
+		}
+
+		parsed, _ := ParseEncoded(encoded)
+		if parsed.Params.Hasher != name {
+			t.Errorf("got hasher %q, wanted %q", parsed.Params.Hasher, name)
+		}
+
+		if ok, err := Verify(encoded, "hunter2"); err != nil || !ok {
+			t.Errorf("%v: got %v %v, wanted a match", name, ok, err)
+		}
+
+		if ok, _ := Verify(encoded, "hunter3"); ok {
+			t.Errorf("%v: got a match for the wrong plaintext", name)
+		}
+	}
+}
+
+func TestHasherDigestsDiffer(t *testing.T) {
+	seen := make(map[string]string)
+	for _, name := range []string{SHA256, SHA512, SHA3512, BLAKE2b, Argon2id, Scrypt} {
+		hasher, _ := LookupHasher(name)
+		digest, err := NewZypher(WithHasher(hasher)).Zyph("hunter2")
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", name, err)
+		}
+
+		if other, ok := seen[digest]; ok {
+			t.Errorf("%v and %v produced the same digest", name, other)
+		}
+		seen[digest] = name
+	}
+}
+
+func TestDefaultHasherUnchanged(t *testing.T) {
+	sha, _ := LookupHasher(SHA512)
+	want, _ := NewZypher().Zyph("hunter2")
+	got, _ := NewZypher(WithHasher(sha)).Zyph("hunter2")
+
+	if got != want {
+		t.Errorf("got %q, wanted the default sha512 digest %q", got, want)
+	}
+}
+
+func TestKdfSinglePass(t *testing.T) {
+	for _, name := range []string{Argon2id, Scrypt} {
+		trace := &Trace{}
+		many, err := NewZypher(WithHasher(hashers[name]), WithHashIterCount(1000), WithTrace(trace)).Zyph("hunter2")
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", name, err)
+		}
+
+		one, _ := NewZypher(WithHasher(hashers[name]), WithHashIterCount(1)).Zyph("hunter2")
+		if many != one {
+			t.Errorf("%v: got %q, wanted the single pass digest %q", name, many, one)
+		}
+
+		passes := 0
+		for _, step := range trace.Steps {
+			if step.Stage == StageHash {
+				passes++
+			}
+		}
+		if passes != 1 {
+			t.Errorf("%v: got %v hash passes, wanted 1", name, passes)
+		}
+	}
+}
+
+func TestPepperedKdf(t *testing.T) {
+	argon, _ := LookupHasher(Argon2id)
+	encoded, _ := NewZypher(WithHasher(argon), WithPepper("server secret")).Hash("hunter2")
+
+	if ok, _ := Verify(encoded, "hunter2"); ok {
+		t.Errorf("got a match without the pepper, wanted no match")
+	}
+
+	if ok, err := Verify(encoded, "hunter2", WithPepper("server secret")); err != nil || !ok {
+		t.Errorf("got %v %v, wanted a match with the pepper", ok, err)
+	}
+}
+
+func TestLookupHasher(t *testing.T) {
+	if h, err := LookupHasher(""); err != nil || h.Name() != SHA512 {
+		t.Errorf("got %v %v, wanted sha512 for an empty name", h, err)
+	}
+
+	if h, err := LookupHasher("SHA3-512"); err != nil || h.Name() != SHA3512 {
+		t.Errorf("got %v %v, wanted names to be case insensitive", h, err)
+	}
+
+	if _, err := LookupHasher("md5"); !errors.Is(err, ErrUnknownHasher) {
+		t.Errorf("got %v, wanted ErrUnknownHasher", err)
+	}
+}
@@ -0,0 +1,101 @@
+package zypher
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// hkdf allows at most 255 blocks of output, the same limit is kept here
+const maxDeriveBlocks = 255
+
+var (
+	ErrMissingKey       = errors.New("a zypher mac needs a key")
+	ErrNotDeterministic = errors.New("bcrypt salts every digest so the same input never gives the same result")
+	ErrDigestOnly       = errors.New("only the sha256, sha512, sha3-512 and blake2b hashers can be used here")
+	ErrDeriveLength     = errors.New("invalid derived key length")
+)
+
+// MAC is an hmac of the raw msg with the zypher's digest. The shift stage is left out on purpose,
+// it maps different characters to the same one so two messages could share a mac
+func (z Zypher) MAC(key []byte, msg string) (string, error) {
+	mac, err := z.macBytes(key, []byte(msg))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(mac), nil
+}
+
+// VerifyMAC reports whether mac is the MAC of msg, in constant time
+func (z Zypher) VerifyMAC(key []byte, msg, mac string) (bool, error) {
+	expected, err := z.MAC(key, msg)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(mac)) == 1, nil
+}
+
+// DeriveKey is hkdf with the zypher's digest, the secret is extracted with the salt as the key and then expanded
+// one block at a time, each block is the MAC of the previous block, info and the block number.
+// An empty salt is a block of zeros as long as the digest
+func (z Zypher) DeriveKey(secret string, salt []byte, info string, length int) ([]byte, error) {
+	if length <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrDeriveLength, length)
+	}
+
+	d, err := z.digest()
+	if err != nil {
+		return nil, err
+	}
+	size := d.new().Size()
+	if maxLength := maxDeriveBlocks * size; length > maxLength {
+		return nil, fmt.Errorf("%w: %d, at most %d bytes can be derived with %v", ErrDeriveLength, length, maxLength, d.name)
+	}
+
+	if len(salt) == 0 {
+		salt = make([]byte, size)
+	}
+	prk, err := z.macBytes(salt, []byte(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	okm := make([]byte, 0, length+size)
+	var block []byte
+	for i := 1; len(okm) < length; i++ {
+		msg := append(append(block, info...), byte(i))
+		if block, err = z.macBytes(prk, msg); err != nil {
+			return nil, err
+		}
+		okm = append(okm, block...)
+	}
+	return okm[:length], nil
+}
+
+// macBytes is the raw hmac behind MAC and DeriveKey
+func (z Zypher) macBytes(key, msg []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrMissingKey
+	}
+
+	d, err := z.digest()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(d.new, key)
+	mac.Write(msg)
+	return mac.Sum(nil), nil
+}
+
+// digest returns the zypher's hasher when it is a plain digest, the kdfs are too costly to key and bcrypt is random
+func (z Zypher) digest() (digestHasher, error) {
+	switch h := z.hasher().(type) {
+	case digestHasher:
+		return h, nil
+	case bcryptHasher:
+		return digestHasher{}, ErrNotDeterministic
+	}
+	return digestHasher{}, ErrDigestOnly
+}
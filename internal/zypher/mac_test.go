@@ -0,0 +1,115 @@
+package zypher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestMAC(t *testing.T) {
+	zy := NewZypher()
+	key := []byte("signing key")
+
+	mac, err := zy.MAC(key, "uid=42;exp=1700000000")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if ok, _ := zy.VerifyMAC(key, "uid=42;exp=1700000000", mac); !ok {
+		t.Errorf("got no match, wanted the mac to verify")
+	}
+
+	if ok, _ := zy.VerifyMAC(key, "uid=43;exp=1700000000", mac); ok {
+		t.Errorf("got a match for a different message")
+	}
+
+	if ok, _ := zy.VerifyMAC([]byte("other key"), "uid=42;exp=1700000000", mac); ok {
+		t.Errorf("got a match for a different key")
+	}
+
+	plain, _ := zy.Zyph("uid=42;exp=1700000000")
+	if plain == mac {
+		t.Errorf("got the unkeyed digest, wanted the key to change it")
+	}
+
+	// the shift stage turns both a space and a u into an x, a mac must still tell them apart
+	spaced, _ := zy.MAC(key, "uid=42 admin")
+	joined, _ := zy.MAC(key, "uid=42uadmin")
+	if spaced == joined {
+		t.Errorf("got the same mac for different messages")
+	}
+}
+
+func TestMACInvalid(t *testing.T) {
+	if _, err := NewZypher().MAC(nil, "msg"); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("got %v, wanted ErrMissingKey", err)
+	}
+
+	if _, err := NewZypher(WithHasher(hashers[Bcrypt])).MAC([]byte("key"), "msg"); !errors.Is(err, ErrNotDeterministic) {
+		t.Errorf("got %v, wanted ErrNotDeterministic", err)
+	}
+
+	for _, name := range []string{Argon2id, Scrypt} {
+		if _, err := NewZypher(WithHasher(hashers[name])).DeriveKey("secret", nil, "", 32); !errors.Is(err, ErrDigestOnly) {
+			t.Errorf("got %v for %v, wanted ErrDigestOnly", err, name)
+		}
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	zy := NewZypher(WithHashIterCount(1))
+
+	long, err := zy.DeriveKey("master secret", []byte("salt"), "session", 150)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(long) != 150 {
+		t.Errorf("got %v bytes, wanted 150", len(long))
+	}
+
+	// a shorter key is a prefix of a longer one with the same inputs, as in hkdf
+	short, _ := zy.DeriveKey("master secret", []byte("salt"), "session", 32)
+	if !bytes.Equal(short, long[:32]) {
+		t.Errorf("got %x, wanted the first 32 bytes of %x", short, long)
+	}
+
+	other, _ := zy.DeriveKey("master secret", []byte("salt"), "email", 32)
+	if bytes.Equal(short, other) {
+		t.Errorf("got the same key for different info")
+	}
+
+	spaced, _ := zy.DeriveKey("master secret", []byte("salt"), "a b", 32)
+	joined, _ := zy.DeriveKey("master secret", []byte("salt"), "aub", 32)
+	if bytes.Equal(spaced, joined) {
+		t.Errorf("got the same key for different info")
+	}
+
+	unsalted, err := zy.DeriveKey("master secret", nil, "session", 32)
+	if err != nil || bytes.Equal(unsalted, short) {
+		t.Errorf("got %x %v, wanted a different key without the salt", unsalted, err)
+	}
+
+	if _, err := zy.DeriveKey("master secret", nil, "", 255*64+1); !errors.Is(err, ErrDeriveLength) {
+		t.Errorf("got %v, wanted ErrDeriveLength", err)
+	}
+}
+
+func TestDeriveKeyMatchesHKDF(t *testing.T) {
+	for _, salt := range [][]byte{nil, []byte("salt")} {
+		got, err := NewZypher(WithHasher(hashers[SHA256])).DeriveKey("master secret", salt, "session", 100)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		wanted := make([]byte, 100)
+		io.ReadFull(hkdf.New(sha256.New, []byte("master secret"), salt, []byte("session")), wanted)
+		if !bytes.Equal(got, wanted) {
+			t.Errorf("got %x, wanted the hkdf key %x", got, wanted)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package zypher
+
+import (
+	"encoding/base64"
+
+	"github.com/Z3DRP/zportfolio-service/config"
+)
+
+// hasherStrength ranks the hashers from weakest to strongest, hashers with the same rank are not upgraded between
+var hasherStrength = map[string]int{
+	SHA256:   1,
+	SHA512:   2,
+	SHA3512:  2,
+	BLAKE2b:  2,
+	Bcrypt:   3,
+	Scrypt:   4,
+	Argon2id: 5,
+}
+
+// Policy is the minimum cost a stored hash has to meet, new hashes are made with its params
+type Policy struct {
+	Params     Params
+	SaltLength int
+	pepper     []byte
+}
+
+func NewPolicy(cfg config.ZypherConfig) (*Policy, error) {
+	zy, err := FromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{Params: zy.Params(), SaltLength: zy.SaltLength, pepper: zy.pepper}, nil
+}
+
+func (p Policy) Zypher() (*Zypher, error) {
+	return p.Params.Zypher(WithSaltLength(p.SaltLength), p.withPepper)
+}
+
+func (p Policy) withPepper(z *Zypher) {
+	z.pepper = p.pepper
+}
+
+// Weaker reports whether a stored hash costs less than the policy. A weaker hasher, fewer rounds of the same kind of hasher,
+// lower kdf costs or a shorter salt all count, shift settings add no real cost so changing them does not
+func (p Policy) Weaker(stored EncodedHash) bool {
+	storedRank, policyRank := hasherStrength[stored.Params.Hasher], hasherStrength[p.Params.Hasher]
+	if storedRank != policyRank {
+		return storedRank < policyRank
+	}
+
+	storedHasher, err := LookupHasher(stored.Params.Hasher)
+	if err != nil {
+		return true
+	}
+	if storedHasher.Iterative() && stored.Params.HashIterCount < p.Params.HashIterCount {
+		return true
+	}
+	if storedHasher.Name() == p.Params.Hasher && kdfWeaker(storedHasher.Name(), stored.Params.Costs, p.Params.Costs) {
+		return true
+	}
+
+	salt, err := base64.RawURLEncoding.DecodeString(stored.Salt)
+	return err != nil || len(salt) < p.SaltLength
+}
+
+// kdfWeaker reports whether any cost that adds work to the kdf is lower in stored than in policy
+func kdfWeaker(hasher string, stored, policy KdfCosts) bool {
+	for _, field := range kdfCostFields[hasher] {
+		if field.work && *field.value(&stored) < *field.value(&policy) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyAndUpgrade verifies plaintext like Verify, using the policy's pepper. When it matches a hash weaker than
+// the policy it also returns the plaintext hashed with the policy, the caller stores it in place of encoded.
+// upgraded is empty when there is nothing to store
+func VerifyAndUpgrade(encoded, plaintext string, policy Policy) (ok bool, upgraded string, err error) {
+	parsed, err := ParseEncoded(encoded)
+	if err != nil {
+		return false, "", err
+	}
+
+	ok, err = parsed.verify(plaintext, policy.withPepper)
+	if err != nil || !ok || !policy.Weaker(*parsed) {
+		return ok, "", err
+	}
+
+	zy, err := policy.Zypher()
+	if err != nil {
+		return true, "", err
+	}
+
+	upgraded, err = zy.Hash(plaintext)
+	if err != nil {
+		return true, "", err
+	}
+	return true, upgraded, nil
+}
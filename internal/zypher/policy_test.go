@@ -0,0 +1,104 @@
+package zypher
+
+import (
+	"testing"
+
+	"github.com/Z3DRP/zportfolio-service/config"
+)
+
+func testPolicy(t *testing.T, cfg config.ZypherConfig) *Policy {
+	policy, err := NewPolicy(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return policy
+}
+
+func TestVerifyAndUpgrade(t *testing.T) {
+	policy := testPolicy(t, config.ZypherConfig{Shift: 3, ShiftCount: 3, HashCount: 10, Hasher: SHA512})
+
+	tests := map[string]struct {
+		ops     []func(*Zypher)
+		upgrade bool
+	}{
+		"fewer rounds":  {[]func(*Zypher){WithHashIterCount(3)}, true},
+		"weaker hasher": {[]func(*Zypher){WithHasher(hashers[SHA256]), WithHashIterCount(50)}, true},
+		"shorter salt":  {[]func(*Zypher){WithHashIterCount(10), WithSaltLength(4)}, true},
+		"same cost":     {[]func(*Zypher){WithHashIterCount(10)}, false},
+		"more rounds":   {[]func(*Zypher){WithHashIterCount(20), WithShift(9)}, false},
+		"same rank":     {[]func(*Zypher){WithHasher(hashers[BLAKE2b]), WithHashIterCount(10)}, false},
+		"stronger kdf":  {[]func(*Zypher){WithHasher(hashers[Argon2id])}, false},
+	}
+
+	for name, tt := range tests {
+		encoded, _ := NewZypher(tt.ops...).Hash("hunter2")
+		ok, upgraded, err := VerifyAndUpgrade(encoded, "hunter2", *policy)
+		if err != nil || !ok {
+			t.Fatalf("%v: got %v %v, wanted a match", name, ok, err)
+		}
+
+		if (upgraded != "") != tt.upgrade {
+			t.Errorf("%v: got upgraded %q, wanted an upgrade %v", name, upgraded, tt.upgrade)
+			continue
+		}
+
+		if upgraded == "" {
+			continue
+		}
+
+		parsed, _ := ParseEncoded(upgraded)
+		if parsed.Params != policy.Params {
+			t.Errorf("%v: got params %+v, wanted the policy %+v", name, parsed.Params, policy.Params)
+		}
+
+		if ok, _ := Verify(upgraded, "hunter2"); !ok {
+			t.Errorf("%v: got no match for the upgraded hash", name)
+		}
+	}
+}
+
+func TestWeakerKdfCosts(t *testing.T) {
+	policy := Policy{Params: Params{Hasher: Argon2id, HashIterCount: 1, Costs: KdfCosts{Time: 2, Memory: 19456, Threads: 1}}}
+	tests := map[string]struct {
+		params Params
+		weaker bool
+	}{
+		"same costs":   {Params{Hasher: Argon2id, Costs: KdfCosts{Time: 2, Memory: 19456, Threads: 1}}, false},
+		"less memory":  {Params{Hasher: Argon2id, Costs: KdfCosts{Time: 2, Memory: 8192, Threads: 1}}, true},
+		"fewer passes": {Params{Hasher: Argon2id, Costs: KdfCosts{Time: 1, Memory: 65536, Threads: 1}}, true},
+		// lanes split the memory rather than adding work
+		"more threads": {Params{Hasher: Argon2id, Costs: KdfCosts{Time: 2, Memory: 19456, Threads: 4}}, false},
+		"higher costs": {Params{Hasher: Argon2id, Costs: KdfCosts{Time: 3, Memory: 65536, Threads: 1}}, false},
+		"weaker kdf":   {Params{Hasher: Scrypt, Costs: KdfCosts{N: 1 << 20}}, true},
+	}
+
+	for name, tt := range tests {
+		if got := policy.Weaker(EncodedHash{Params: tt.params}); got != tt.weaker {
+			t.Errorf("%v: got weaker %v, wanted %v", name, got, tt.weaker)
+		}
+	}
+}
+
+func TestVerifyAndUpgradeMismatch(t *testing.T) {
+	policy := testPolicy(t, config.ZypherConfig{ShiftCount: 3, HashCount: 10})
+	encoded, _ := NewZypher(WithHashIterCount(1)).Hash("hunter2")
+
+	ok, upgraded, err := VerifyAndUpgrade(encoded, "hunter3", *policy)
+	if err != nil || ok || upgraded != "" {
+		t.Errorf("got %v %q %v, wanted no match and no upgrade", ok, upgraded, err)
+	}
+}
+
+func TestVerifyAndUpgradePepper(t *testing.T) {
+	policy := testPolicy(t, config.ZypherConfig{ShiftCount: 3, HashCount: 10, Pepper: "server secret"})
+	encoded, _ := NewZypher(WithHashIterCount(2), WithPepper("server secret")).Hash("hunter2")
+
+	ok, upgraded, err := VerifyAndUpgrade(encoded, "hunter2", *policy)
+	if err != nil || !ok || upgraded == "" {
+		t.Fatalf("got %v %q %v, wanted a match and an upgrade", ok, upgraded, err)
+	}
+
+	if ok, _ := Verify(upgraded, "hunter2", WithPepper("server secret")); !ok {
+		t.Errorf("got no match, wanted the upgraded hash to keep the pepper")
+	}
+}
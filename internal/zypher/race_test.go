@@ -0,0 +1,5 @@
+//go:build race
+
+package zypher
+
+const raceEnabled = true
@@ -0,0 +1,425 @@
+package zypher
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// an offline attacker is assumed to run this many zyphs at once, the memory hard hashers keep far fewer in flight
+	attackerParallelism   = 10_000
+	memoryHardParallelism = 100
+	minStrongLength       = 12
+	minWordLength         = 3
+	// past this a password is strong for its length alone, and the pattern search grows with the square of the length
+	MaxStrengthLength = 256
+	// guess costs are kept for this many sets of params, the service only ever prices its configured one
+	maxGuessCosts = 64
+
+	PatternDictionary = "dictionary"
+	PatternSequence   = "sequence"
+	PatternKeyboard   = "keyboard"
+	PatternRepeat     = "repeat"
+	PatternYear       = "year"
+)
+
+var (
+	ErrEmptyPassword  = errors.New("a password is needed to estimate its strength")
+	ErrPasswordLength = fmt.Errorf("only passwords up to %d characters can be estimated", MaxStrengthLength)
+)
+
+//go:embed wordlist.txt
+var wordlist string
+
+// commonWords holds the embedded common passwords and words, maxWordLength bounds the substrings worth looking up
+var commonWords, maxWordLength = loadWords(wordlist)
+
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+var leetSubstitutions = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '@': 'a', '$': 's', '!': 'i',
+}
+
+var strengthRatings = []string{"very weak", "weak", "fair", "good", "strong"}
+
+// guess costs are measured once per set of params, Params only holds comparable fields so it keys the map
+var guessCosts = struct {
+	sync.Mutex
+	costs map[Params]time.Duration
+}{costs: make(map[Params]time.Duration)}
+
+// Strength is an estimate of how hard a password is to guess, CrackTime assumes an offline attacker
+// with the stored hashes who has to pay GuessCost for every guess
+type Strength struct {
+	Entropy      float64
+	Score        int
+	Rating       string
+	Patterns     []string
+	GuessCost    time.Duration
+	CrackSeconds float64
+	CrackTime    string
+	Feedback     []string
+}
+
+// strengthMatch is a run of the password that is cheaper to guess as a pattern than a character at a time
+type strengthMatch struct {
+	start, end int
+	bits       float64
+	pattern    string
+}
+
+// EstimateStrength rates a password by its entropy and how long zyphs built from ops would take to crack it.
+// Runs that match a common word, a sequence, a keyboard row, a repeat or a year are charged as that pattern
+// and everything else is charged per character for the character classes used
+func EstimateStrength(password string, ops ...func(*Zypher)) (*Strength, error) {
+	return NewZypher(ops...).EstimateStrength(password)
+}
+
+// EstimateStrength is the package EstimateStrength priced with z, measuring the guess cost stops with the zyph's context
+func (z Zypher) EstimateStrength(password string) (*Strength, error) {
+	if password == "" {
+		return nil, ErrEmptyPassword
+	}
+	runes := []rune(password)
+	if len(runes) > MaxStrengthLength {
+		return nil, ErrPasswordLength
+	}
+
+	cost, err := guessCost(&z)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, classes := characterPool(runes)
+	entropy, matches := cheapestSegmentation(runes, pool)
+
+	parallelism := float64(attackerParallelism)
+	if !z.hasher().Iterative() {
+		parallelism = memoryHardParallelism
+	}
+	// on average half the guesses are needed
+	crackSeconds := math.Exp2(entropy-1) * cost.Seconds() / parallelism
+
+	score := 4
+	for i, bits := range []float64{28, 36, 60, 80} {
+		if entropy < bits {
+			score = i
+			break
+		}
+	}
+	if len(runes) < 8 {
+		score = min(score, 1)
+	}
+
+	var patterns []string
+	for _, match := range matches {
+		if !slices.Contains(patterns, match.pattern) {
+			patterns = append(patterns, match.pattern)
+		}
+	}
+	slices.Sort(patterns)
+
+	return &Strength{
+		Entropy:      math.Round(entropy*100) / 100,
+		Score:        score,
+		Rating:       strengthRatings[score],
+		Patterns:     patterns,
+		GuessCost:    cost,
+		CrackSeconds: crackSeconds,
+		CrackTime:    crackTime(crackSeconds),
+		Feedback:     strengthFeedback(len(runes), classes, score, patterns),
+	}, nil
+}
+
+func loadWords(list string) (map[string]bool, int) {
+	words := make(map[string]bool)
+	longest := 0
+	for _, word := range strings.Fields(list) {
+		words[word] = true
+		longest = max(longest, len(word))
+	}
+	return words, longest
+}
+
+func guessCost(zy *Zypher) (time.Duration, error) {
+	params := zy.Params()
+	guessCosts.Lock()
+	cost, ok := guessCosts.costs[params]
+	guessCosts.Unlock()
+	if ok {
+		return cost, nil
+	}
+
+	if _, err := zy.Zyph(calibrationInput); err != nil {
+		return 0, err
+	}
+	var zyphErr error
+	cost = measure(func() {
+		// once the context stops a zyph the rest of the sample is skipped
+		if zyphErr == nil {
+			_, zyphErr = zy.Zyph(calibrationInput)
+		}
+	})
+	if zyphErr != nil {
+		return 0, zyphErr
+	}
+
+	guessCosts.Lock()
+	defer guessCosts.Unlock()
+	if len(guessCosts.costs) >= maxGuessCosts {
+		for stale := range guessCosts.costs {
+			delete(guessCosts.costs, stale)
+			break
+		}
+	}
+	guessCosts.costs[params] = cost
+	return cost, nil
+}
+
+// characterPool is how many characters each position could be given the classes used, and how many classes that is
+func characterPool(runes []rune) (int, int) {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= ' ' && r <= '~':
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool, classes := 0, 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+			classes++
+		}
+	}
+	return pool, classes
+}
+
+// cheapestSegmentation finds the split of the password into patterns and single characters that takes the fewest bits,
+// an attacker tries the cheap guesses first so that is the entropy that counts
+func cheapestSegmentation(runes []rune, pool int) (float64, []strengthMatch) {
+	// candidates are grouped by where they end so each position only looks at the matches that finish there
+	endingAt := make([][]strengthMatch, len(runes)+1)
+	for _, match := range findPatterns(runes, pool) {
+		endingAt[match.end] = append(endingAt[match.end], match)
+	}
+	charBits := math.Log2(float64(pool))
+
+	bits := make([]float64, len(runes)+1)
+	chosen := make([]*strengthMatch, len(runes)+1)
+	for end := 1; end <= len(runes); end++ {
+		bits[end] = bits[end-1] + charBits
+		for i := range endingAt[end] {
+			match := &endingAt[end][i]
+			if bits[match.start]+match.bits < bits[end] {
+				bits[end] = bits[match.start] + match.bits
+				chosen[end] = match
+			}
+		}
+	}
+
+	var matches []strengthMatch
+	for end := len(runes); end > 0; {
+		if chosen[end] == nil {
+			end--
+			continue
+		}
+		matches = append(matches, *chosen[end])
+		end = chosen[end].start
+	}
+	return bits[len(runes)], matches
+}
+
+func findPatterns(runes []rune, pool int) []strengthMatch {
+	lower := make([]rune, len(runes))
+	unleet := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+		unleet[i] = lower[i]
+		if sub, ok := leetSubstitutions[lower[i]]; ok {
+			unleet[i] = sub
+		}
+	}
+
+	var matches []strengthMatch
+	wordBits := math.Log2(float64(len(commonWords)))
+	for start := range runes {
+		for end := start + minWordLength; end <= min(len(runes), start+maxWordLength); end++ {
+			var extra float64
+			switch {
+			case commonWords[string(lower[start:end])]:
+			case commonWords[string(unleet[start:end])]:
+				// each substitution only doubles the guesses
+				for i := start; i < end; i++ {
+					if unleet[i] != lower[i] {
+						extra++
+					}
+				}
+			default:
+				continue
+			}
+			matches = append(matches, strengthMatch{start, end, wordBits + extra + capitalBits(runes[start:end]), PatternDictionary})
+		}
+
+		if end := runEnd(lower, start, func(a, b rune) bool { return a == b }); end-start >= 3 {
+			matches = append(matches, strengthMatch{start, end, math.Log2(float64(pool)) + math.Log2(float64(end-start)), PatternRepeat})
+		}
+		for _, step := range []rune{1, -1} {
+			if end := runEnd(lower, start, func(a, b rune) bool { return b-a == step }); end-start >= 3 {
+				// a sequence is its first character, its length and its direction
+				matches = append(matches, strengthMatch{start, end, math.Log2(float64(pool)) + math.Log2(float64(end-start)) + 1, PatternSequence})
+			}
+		}
+		if end := keyboardRunEnd(lower, start); end-start >= 4 {
+			matches = append(matches, strengthMatch{start, end, math.Log2(float64(len(strings.Join(keyboardRows, "")))) + math.Log2(float64(end-start)) + 1, PatternKeyboard})
+		}
+		if start+4 <= len(runes) && isYear(lower[start:start+4]) {
+			matches = append(matches, strengthMatch{start, start + 4, math.Log2(130), PatternYear})
+		}
+	}
+	return matches
+}
+
+// capitalBits charges a word for its capitals, a capital first letter or all capitals is one more guess
+func capitalBits(word []rune) float64 {
+	upper := 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	if upper == 0 {
+		return 0
+	}
+	if upper == len(word) || (upper == 1 && unicode.IsUpper(word[0])) {
+		return 1
+	}
+	return float64(upper)
+}
+
+// runEnd returns where the run starting at start stops, next reports whether b may follow a
+func runEnd(runes []rune, start int, next func(a, b rune) bool) int {
+	end := start + 1
+	for end < len(runes) && next(runes[end-1], runes[end]) {
+		end++
+	}
+	return end
+}
+
+// keyboardRunEnd returns where a run of neighboring keys on one keyboard row, typed either way, stops
+func keyboardRunEnd(runes []rune, start int) int {
+	longest := start
+	for _, row := range keyboardRows {
+		for _, keys := range []string{row, reverse(row)} {
+			indx := strings.IndexRune(keys, runes[start])
+			if indx < 0 {
+				continue
+			}
+			end := start + 1
+			for end < len(runes) && indx+end-start < len(keys) && rune(keys[indx+end-start]) == runes[end] {
+				end++
+			}
+			longest = max(longest, end)
+		}
+	}
+	return longest
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	slices.Reverse(runes)
+	return string(runes)
+}
+
+// isYear matches 1900 through 2029, the years people put in passwords
+func isYear(digits []rune) bool {
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	year := string(digits)
+	return year >= "1900" && year <= "2029"
+}
+
+func crackTime(seconds float64) string {
+	units := []struct {
+		name    string
+		seconds float64
+	}{
+		{"century", 100 * 365.25 * 24 * 3600},
+		{"year", 365.25 * 24 * 3600},
+		{"month", 30.44 * 24 * 3600},
+		{"day", 24 * 3600},
+		{"hour", 3600},
+		{"minute", 60},
+		{"second", 1},
+	}
+
+	if seconds < 1 {
+		return "less than a second"
+	}
+	if seconds >= 100*units[0].seconds {
+		return "centuries"
+	}
+	for _, unit := range units {
+		if seconds >= unit.seconds {
+			count := int(seconds / unit.seconds)
+			if count == 1 {
+				return "1 " + unit.name
+			}
+			if unit.name == "century" {
+				return fmt.Sprintf("%d centuries", count)
+			}
+			return fmt.Sprintf("%d %ss", count, unit.name)
+		}
+	}
+	return "less than a second"
+}
+
+func strengthFeedback(length, classes, score int, patterns []string) []string {
+	var feedback []string
+	if length < minStrongLength {
+		feedback = append(feedback, fmt.Sprintf("use at least %d characters, length adds more than symbols do", minStrongLength))
+	}
+	if classes < 3 && score < 4 {
+		feedback = append(feedback, "mix in upper case letters, digits or symbols")
+	}
+	for _, pattern := range patterns {
+		switch pattern {
+		case PatternDictionary:
+			feedback = append(feedback, "avoid common words and passwords, capitals and substitutions like @ for a barely help")
+		case PatternSequence, PatternKeyboard:
+			if tip := "avoid sequences and keyboard runs such as abc, 123 or qwerty"; !slices.Contains(feedback, tip) {
+				feedback = append(feedback, tip)
+			}
+		case PatternRepeat:
+			feedback = append(feedback, "avoid repeating the same character")
+		case PatternYear:
+			feedback = append(feedback, "avoid years and dates, they are among the first guesses")
+		}
+	}
+	if len(feedback) == 0 {
+		feedback = append(feedback, "no obvious weaknesses found")
+	}
+	return feedback
+}
@@ -0,0 +1,134 @@
+package zypher
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateStrength(t *testing.T) {
+	cases := []struct {
+		password string
+		maxScore int
+		minScore int
+		pattern  string
+	}{
+		{"password", 0, 0, PatternDictionary},
+		{"P@ssw0rd", 1, 0, PatternDictionary},
+		{"abcdefgh", 1, 0, PatternSequence},
+		{"qwertyui", 1, 0, PatternKeyboard},
+		{"aaaaaaaaaaaa", 1, 0, PatternRepeat},
+		{"monkey1987", 1, 0, PatternYear},
+		{"x7#Qm!2vLp9$Rz", 4, 3, ""},
+		{"correct horse battery staple", 4, 3, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.password, func(t *testing.T) {
+			strength, err := EstimateStrength(c.password, WithHasher(hashers[SHA256]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strength.Score < c.minScore || strength.Score > c.maxScore {
+				t.Errorf("got a score of %d with %f bits, wanted a score from %d to %d", strength.Score, strength.Entropy, c.minScore, c.maxScore)
+			}
+			if c.pattern != "" && !slices.Contains(strength.Patterns, c.pattern) {
+				t.Errorf("got patterns %v, wanted the %s pattern", strength.Patterns, c.pattern)
+			}
+			if strength.Rating != strengthRatings[strength.Score] || len(strength.Feedback) == 0 || strength.CrackTime == "" {
+				t.Errorf("got %+v, wanted a rating, feedback and crack time", strength)
+			}
+		})
+	}
+}
+
+func TestEstimateStrengthCost(t *testing.T) {
+	fast, err := EstimateStrength("a reasonably long pass phrase", WithHasher(hashers[SHA256]), WithHashIterCount(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, err := EstimateStrength("a reasonably long pass phrase", WithHasher(hashers[SHA256]), WithHashIterCount(2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fast.Entropy != slow.Entropy {
+		t.Errorf("got entropies %f and %f, wanted the entropy to ignore the zypher cost", fast.Entropy, slow.Entropy)
+	}
+	if slow.CrackSeconds <= fast.CrackSeconds {
+		t.Errorf("got %f and %f seconds, wanted more hash iterations to take longer to crack", fast.CrackSeconds, slow.CrackSeconds)
+	}
+}
+
+func TestEstimateStrengthContext(t *testing.T) {
+	// a single zyph with this many sha3-512 rounds takes a couple of seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	zy := NewZypher(WithHasher(hashers[SHA3512]), WithHashIterCount(MaxIterCount), WithContext(ctx))
+
+	start := time.Now()
+	if _, err := zy.EstimateStrength("a reasonably long pass phrase"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, wanted the deadline to stop the guess cost", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("got %v, wanted the estimate to stop inside its first zyph", elapsed)
+	}
+
+	guessCosts.Lock()
+	_, cached := guessCosts.costs[zy.Params()]
+	guessCosts.Unlock()
+	if cached {
+		t.Errorf("got a guess cost cached for a measurement that was stopped, wanted none")
+	}
+}
+
+func TestGuessCostsBounded(t *testing.T) {
+	guessCosts.Lock()
+	for i := 0; len(guessCosts.costs) < maxGuessCosts; i++ {
+		guessCosts.costs[Params{Shift: -i - 1, Hasher: SHA256}] = time.Microsecond
+	}
+	guessCosts.Unlock()
+
+	if _, err := EstimateStrength("a reasonably long pass phrase", WithHasher(hashers[SHA256]), WithHashIterCount(1), WithShift(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	guessCosts.Lock()
+	defer guessCosts.Unlock()
+	if len(guessCosts.costs) > maxGuessCosts {
+		t.Errorf("got %d cached guess costs, wanted at most %d", len(guessCosts.costs), maxGuessCosts)
+	}
+}
+
+func TestEstimateStrengthEmpty(t *testing.T) {
+	if _, err := EstimateStrength(""); !errors.Is(err, ErrEmptyPassword) {
+		t.Errorf("got %v, wanted %v", err, ErrEmptyPassword)
+	}
+}
+
+func TestEstimateStrengthLength(t *testing.T) {
+	if _, err := EstimateStrength(strings.Repeat("é", MaxStrengthLength+1)); !errors.Is(err, ErrPasswordLength) {
+		t.Errorf("got %v, wanted %v", err, ErrPasswordLength)
+	}
+	if _, err := EstimateStrength(strings.Repeat("é", MaxStrengthLength), WithHasher(hashers[SHA256])); err != nil {
+		t.Errorf("got %v, wanted %d runes to be estimated", err, MaxStrengthLength)
+	}
+}
+
+func TestCrackTime(t *testing.T) {
+	for seconds, want := range map[float64]string{
+		0.2:         "less than a second",
+		1:           "1 second",
+		90:          "1 minute",
+		7200:        "2 hours",
+		4e9:         "1 century",
+		1e300:       "centuries",
+		3 * 86400.0: "3 days",
+	} {
+		if got := crackTime(seconds); got != want {
+			t.Errorf("crackTime(%v): got %q, wanted %q", seconds, got, want)
+		}
+	}
+}
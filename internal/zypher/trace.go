@@ -0,0 +1,75 @@
+package zypher
+
+import "time"
+
+const (
+	StageShift = "shift"
+	StageHash  = "hash"
+)
+
+// Step is the output of a single shift or hash iteration
+type Step struct {
+	Stage     string
+	Iteration int
+	Output    string
+	Duration  time.Duration
+}
+
+// Trace collects every step of a zyph, a zypher only records into it when one is set with WithTrace
+type Trace struct {
+	Steps []Step
+}
+
+// StageDuration is the total time spent in every step of a stage
+func (t *Trace) StageDuration(stage string) time.Duration {
+	var total time.Duration
+	for _, step := range t.Steps {
+		if step.Stage == stage {
+			total += step.Duration
+		}
+	}
+	return total
+}
+
+// WithTrace records each iteration into t, a trace is not safe to share between zyphs running at the same time
+func WithTrace(t *Trace) func(*Zypher) {
+	return func(z *Zypher) {
+		z.trace = t
+	}
+}
+
+// stepStart only reads the clock when tracing so the normal path does no extra work
+func (z Zypher) stepStart() time.Time {
+	if z.trace == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func (z Zypher) record(stage string, iteration int, output string, start time.Time) {
+	if z.trace == nil {
+		return
+	}
+	z.trace.Steps = append(z.trace.Steps, Step{
+		Stage:     stage,
+		Iteration: iteration,
+		Output:    output,
+		Duration:  time.Since(start),
+	})
+}
+
+// recordRunes keeps the shift loops from building a string when nothing is tracing
+func (z Zypher) recordRunes(stage string, iteration int, output []rune, start time.Time) {
+	if z.trace == nil {
+		return
+	}
+	z.record(stage, iteration, string(output), start)
+}
+
+// recordBytes is recordRunes for the ascii and hash stages
+func (z Zypher) recordBytes(stage string, iteration int, output []byte, start time.Time) {
+	if z.trace == nil {
+		return
+	}
+	z.record(stage, iteration, string(output), start)
+}
@@ -0,0 +1,52 @@
+package zypher
+
+import "testing"
+
+func TestZyphTrace(t *testing.T) {
+	trace := &Trace{}
+	zy := NewZypher(WithShiftIterCount(2), WithHashIterCount(3), WithTrace(trace))
+	reslt, err := zy.Zyph("trace me")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(trace.Steps) != 5 {
+		t.Fatalf("got %v steps, wanted 5", len(trace.Steps))
+	}
+
+	for i, stage := range []string{StageShift, StageShift, StageHash, StageHash, StageHash} {
+		if trace.Steps[i].Stage != stage {
+			t.Errorf("step %v: got stage %q, wanted %q", i, trace.Steps[i].Stage, stage)
+		}
+	}
+
+	if last := trace.Steps[4].Output; last != reslt {
+		t.Errorf("got last step %q, wanted the result %q", last, reslt)
+	}
+
+	untraced, _ := NewZypher(WithShiftIterCount(2), WithHashIterCount(3)).Zyph("trace me")
+	if untraced != reslt {
+		t.Errorf("got %q, wanted tracing to leave the result unchanged %q", untraced, reslt)
+	}
+}
+
+func TestAsciZyphTrace(t *testing.T) {
+	trace := &Trace{}
+	zy := NewZypher(WithShiftIterCount(2), WithTrace(trace))
+	zy.AsciZyph("Abc")
+
+	want := []string{"Def", "Ghi"}
+	if len(trace.Steps) != len(want) {
+		t.Fatalf("got %v steps, wanted %v", len(trace.Steps), len(want))
+	}
+
+	for i, step := range trace.Steps {
+		if step.Output != want[i] || step.Iteration != i {
+			t.Errorf("got step %+v, wanted iteration %v output %q", step, i, want[i])
+		}
+	}
+
+	if trace.StageDuration(StageHash) != 0 {
+		t.Errorf("got hash time %v, wanted none for a shift only zyph", trace.StageDuration(StageHash))
+	}
+}
@@ -0,0 +1,119 @@
+package zypher
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// shiftCategories are the alphabets a non ascii rune is shifted within, a rune only ever moves to another rune of
+// its own general category. ascii keeps its original digit and letter alphabets so existing digests do not change
+var shiftCategories = []*unicode.RangeTable{
+	unicode.Lu, unicode.Ll, unicode.Lt, unicode.Lm, unicode.Lo,
+	unicode.Mn, unicode.Mc, unicode.Me,
+	unicode.Nd, unicode.Nl, unicode.No,
+	unicode.Pc, unicode.Pd, unicode.Ps, unicode.Pe, unicode.Pi, unicode.Pf, unicode.Po,
+	unicode.Sm, unicode.Sc, unicode.Sk, unicode.So,
+	unicode.Zs,
+}
+
+var categorySizes = func() map[*unicode.RangeTable]int {
+	sizes := make(map[*unicode.RangeTable]int, len(shiftCategories))
+	for _, table := range shiftCategories {
+		sizes[table] = tableSize(table)
+	}
+	return sizes
+}()
+
+// isZyphable accepts valid utf-8 made of printable runes and spaces, format runes are allowed so
+// emoji joined with a zero width joiner pass
+func isZyphable(arg string) bool {
+	if arg == "" || !utf8.ValidString(arg) {
+		return false
+	}
+
+	for _, r := range arg {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) && !unicode.Is(unicode.Cf, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// categoryShift moves r shf places within its general category, runes outside every category are left as is
+func categoryShift(r rune, shf int, ignSpc bool) rune {
+	if ignSpc && unicode.IsSpace(r) {
+		return r
+	}
+
+	for _, table := range shiftCategories {
+		if !unicode.Is(table, r) {
+			continue
+		}
+		idx := tableIndex(table, r)
+		return tableRune(table, int(wrap(rune(idx), shf, categorySizes[table])))
+	}
+	return r
+}
+
+func tableSize(table *unicode.RangeTable) int {
+	size := 0
+	for _, rng := range table.R16 {
+		size += int((rng.Hi-rng.Lo)/rng.Stride) + 1
+	}
+	for _, rng := range table.R32 {
+		size += int((rng.Hi-rng.Lo)/rng.Stride) + 1
+	}
+	return size
+}
+
+// tableIndex is the position of r in table counting every rune in its ranges in order, r must be in the table
+func tableIndex(table *unicode.RangeTable, r rune) int {
+	idx := 0
+	for _, rng := range table.R16 {
+		lo, hi, stride := rune(rng.Lo), rune(rng.Hi), rune(rng.Stride)
+		if r >= lo && r <= hi {
+			return idx + int((r-lo)/stride)
+		}
+		idx += int((hi-lo)/stride) + 1
+	}
+	for _, rng := range table.R32 {
+		lo, hi, stride := rune(rng.Lo), rune(rng.Hi), rune(rng.Stride)
+		if r >= lo && r <= hi {
+			return idx + int((r-lo)/stride)
+		}
+		idx += int((hi-lo)/stride) + 1
+	}
+	return idx
+}
+
+func tableRune(table *unicode.RangeTable, idx int) rune {
+	for _, rng := range table.R16 {
+		count := int((rng.Hi-rng.Lo)/rng.Stride) + 1
+		if idx < count {
+			return rune(rng.Lo) + rune(idx)*rune(rng.Stride)
+		}
+		idx -= count
+	}
+	for _, rng := range table.R32 {
+		count := int((rng.Hi-rng.Lo)/rng.Stride) + 1
+		if idx < count {
+			return rune(rng.Lo) + rune(idx)*rune(rng.Stride)
+		}
+		idx -= count
+	}
+	return 0
+}
+
+// isZyphableAscii reports whether src is ascii that isZyphable accepts, ascii needs no normalizing so it can skip the rune path
+func isZyphableAscii(src []byte) bool {
+	if len(src) == 0 {
+		return false
+	}
+
+	for _, b := range src {
+		if (b < ' ' || b > '~') && (b < '\t' || b > '\r') {
+			return false
+		}
+	}
+	return true
+}
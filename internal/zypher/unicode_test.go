@@ -0,0 +1,89 @@
+package zypher
+
+import (
+	"testing"
+	"unicode"
+)
+
+func TestZyphNormalizesInput(t *testing.T) {
+	zy := NewZypher()
+	composed, err := zy.Zyph("caf\u00e9")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	decomposed, _ := zy.Zyph("cafe\u0301")
+	if composed != decomposed {
+		t.Errorf("got %q and %q, wanted composed and decomposed input to match", composed, decomposed)
+	}
+}
+
+func TestZyphAcceptsUnicode(t *testing.T) {
+	zy := NewZypher()
+	for _, v := range []string{"p@ss*^~/", "Grüße aus Köln", "пароль", "密码", "🔑👩‍💻"} {
+		if _, err := zy.Zyph(v); err != nil {
+			t.Errorf("%q: unexpected error %v", v, err)
+		}
+	}
+
+	for _, v := range []string{"", "bad\x00byte", "bad\xffutf8"} {
+		if _, err := zy.Zyph(v); err == nil {
+			t.Errorf("%q: got no error, wanted the input rejected", v)
+		}
+	}
+}
+
+func TestZyphShiftsMultibyteRunes(t *testing.T) {
+	zy := NewZypher(WithShiftIterCount(2), WithHashIterCount(0), WithAlternate(true))
+	v := "añb€c"
+	reslt, _ := zy.Zyph(v)
+
+	got, want := []rune(reslt), []rune(v)
+	if len(got) != len(want) {
+		t.Fatalf("got %q, wanted %v runes", reslt, len(want))
+	}
+
+	for i := range want {
+		if got[i] == 0 {
+			t.Errorf("got an empty rune at %v in %q", i, reslt)
+		}
+	}
+
+	// alternating goes by rune index, by byte index b and c would have been shifted the other way
+	if got[0] != 'g' || got[2] != 'h' || got[4] != 'i' {
+		t.Errorf("got %q, wanted the even runes shifted forward", reslt)
+	}
+}
+
+func TestAsciiDigestsUnchanged(t *testing.T) {
+	zy := NewZypher(WithShiftIterCount(1), WithHashIterCount(0))
+	reslt, _ := zy.Zyph("Abc Z19!")
+	want := "DefxC42!"
+
+	if reslt != want {
+		t.Errorf("got %q, wanted %q", reslt, want)
+	}
+}
+
+func TestCategoryShift(t *testing.T) {
+	for _, r := range []rune{'é', 'Ж', 'ß', '٣', '€', '😀', ' '} {
+		shifted := categoryShift(r, 7, false)
+		if shifted == r {
+			t.Errorf("%q: got the same rune, wanted it shifted", r)
+		}
+
+		for _, table := range shiftCategories {
+			if unicode.Is(table, r) != unicode.Is(table, shifted) {
+				t.Errorf("%q: got %q, wanted it to stay in the same category", r, shifted)
+			}
+		}
+
+		if back := categoryShift(shifted, -7, false); back != r {
+			t.Errorf("%q: got %q shifting back, wanted the original", r, back)
+		}
+	}
+
+	if got := categoryShift(' ', 7, true); got != ' ' {
+		t.Errorf("got %q, wanted ignore space to keep a no break space", got)
+	}
+}
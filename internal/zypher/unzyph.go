@@ -0,0 +1,139 @@
+package zypher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// spaceMarker stands in for a space when LosslessSpace is set, it is outside every zypher input alphabet so it can not collide
+const spaceMarker = '~'
+
+var ErrNotReversible = errors.New("zyphered text can not be reversed with these settings")
+
+// AsciUnzyph undoes AsciZyph for the same settings. Without LosslessSpace or IgnoreSpace a space was shifted to x,
+// so it comes back as whichever letter shifts to x
+func (z Zypher) AsciUnzyph(arg string) (string, error) {
+	isValidString := asciUnzyphValidator.MatchString(arg)
+	if !isValidString {
+		return "", fmt.Errorf("%w, only strings containing numbers, letters, spaces, and ~ are allowed", ErrInvalidInput)
+	}
+
+	if z.ShiftIterCount <= 0 {
+		MissingShiftIterCount := errors.New(`invalid zypher, shift iter count expected but not found`)
+		return "", MissingShiftIterCount
+	}
+
+	result, err := z.unshiftRounds(arg, asciUnshift)
+	if err != nil {
+		return "", err
+	}
+	if z.LosslessSpace && !z.IgnoreSpace {
+		result = strings.ReplaceAll(result, string(spaceMarker), " ")
+	}
+	return z.confirmReversed(result, arg, z.AsciZyph)
+}
+
+// HexUnzyph undoes HexZyph for the same settings
+func (z Zypher) HexUnzyph(arg string) (string, error) {
+	isValidString := printableValidator.MatchString(arg)
+	if !isValidString {
+		return "", fmt.Errorf("%w, only printable ascii characters are allowed", ErrInvalidInput)
+	}
+
+	if z.ShiftIterCount <= 0 {
+		MissingShiftIterCount := errors.New(`invalid zypher, shift iter count expected but not found`)
+		return "", MissingShiftIterCount
+	}
+
+	unshift := printableUnshift
+	if z.RestrictHashShift {
+		unshift = hexUnshift
+	}
+	result, err := z.unshiftRounds(arg, unshift)
+	if err != nil {
+		return "", err
+	}
+	return z.confirmReversed(result, arg, z.HexZyph)
+}
+
+// unshiftRounds applies the inverse shift once per shift iteration, every round uses the same shift so the order does not matter
+func (z Zypher) unshiftRounds(arg string, unshift func(r rune, shf int, ignSpc bool) rune) (string, error) {
+	result := []rune(arg)
+	for i := 0; i < z.ShiftIterCount; i++ {
+		if err := z.canceled(); err != nil {
+			return "", err
+		}
+		for indx, r := range result {
+			result[indx] = unshift(r, z.unshiftBy(indx), z.IgnoreSpace)
+		}
+	}
+	return string(result), nil
+}
+
+// unshiftBy is the opposite of the shift the forward functions apply at indx, alternating only flips a positive shift
+func (z Zypher) unshiftBy(indx int) int {
+	shf := z.Shift
+	if z.Alternate && indx%2 != 0 && shf > 0 {
+		shf = -shf
+	}
+	return -shf
+}
+
+// confirmReversed zyphs the result again, a shifted character can land in another character's range
+// so a result that does not zyph back to the input is not the original text
+func (z Zypher) confirmReversed(result, arg string, zyph func(string) (string, error)) (string, error) {
+	again, err := zyph(result)
+	if cerr := z.canceled(); cerr != nil {
+		return "", cerr
+	}
+	if err != nil || again != arg {
+		return "", ErrNotReversible
+	}
+	return result, nil
+}
+
+func asciUnshift(r rune, shf int, ignSpc bool) rune {
+	switch {
+	case r >= '0' && r <= '9':
+		return '0' + wrap(r-'0', shf, 10)
+	case r >= 'A' && r <= 'Z':
+		return 'A' + wrap(r-'A', shf, 26)
+	case r >= 'a' && r <= 'z':
+		return 'a' + wrap(r-'a', shf, 26)
+	}
+	return r
+}
+
+func hexUnshift(r rune, shf int, ignSpc bool) rune {
+	switch {
+	case r >= '0' && r <= '9':
+		return '0' + wrap(r-'0', shf, 10)
+	case r >= 'a' && r <= 'f':
+		return 'a' + wrap(r-'a', shf, 6)
+	case r >= 'A' && r <= 'F':
+		return 'A' + wrap(r-'A', shf, 6)
+	case r == ' ' && ignSpc:
+		return r
+	}
+	return 32 + wrap(r-32, shf, 95)
+}
+
+func printableUnshift(r rune, shf int, ignSpc bool) rune {
+	switch {
+	case r >= '0' && r <= '9':
+		return '0' + wrap(r-'0', shf, 10)
+	case r >= 'A' && r <= 'Z':
+		return 'A' + wrap(r-'A', shf, 26)
+	case r >= 'a' && r <= 'z':
+		return 'a' + wrap(r-'a', shf, 26)
+	case r == ' ' && ignSpc:
+		return r
+	}
+	return 32 + wrap(r-32, shf, 95)
+}
+
+// wrap shifts an offset within a range of size n, unlike % it never goes negative for large shifts
+func wrap(offset rune, shf, n int) rune {
+	return rune(((int(offset)+shf)%n + n) % n)
+}
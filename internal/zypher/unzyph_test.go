@@ -0,0 +1,88 @@
+package zypher
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAsciUnzyph(t *testing.T) {
+	zy := NewZypher(WithShift(5), WithShiftIterCount(4), WithAlternate(true), WithIgnoreSpace(true))
+	v := "Hello World 2024"
+	zyphed, _ := zy.AsciZyph(v)
+
+	reslt, err := zy.AsciUnzyph(zyphed)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if reslt != v {
+		t.Errorf("got %q, wanted %q", reslt, v)
+	}
+}
+
+func TestAsciUnzyphLosslessSpace(t *testing.T) {
+	v := "Abc Z19"
+	zy := NewZypher(WithShiftIterCount(1), WithLosslessSpace(true))
+	zyphed, _ := zy.AsciZyph(v)
+	if zyphed != "Def~C42" {
+		t.Errorf("got %q, wanted %q", zyphed, "Def~C42")
+	}
+
+	reslt, _ := zy.AsciUnzyph(zyphed)
+	if reslt != v {
+		t.Errorf("got %q, wanted %q", reslt, v)
+	}
+
+	// without the lossless mode the space collapses into x and comes back as a letter
+	lossy := NewZypher(WithShiftIterCount(1))
+	zyphed, _ = lossy.AsciZyph(v)
+	reslt, _ = lossy.AsciUnzyph(zyphed)
+	if reslt != "AbcuZ19" {
+		t.Errorf("got %q, wanted %q", reslt, "AbcuZ19")
+	}
+}
+
+func TestHexUnzyph(t *testing.T) {
+	v := "a0 ff9 3bc"
+	for _, restrict := range []bool{true, false} {
+		zy := NewZypher(WithShift(4), WithShiftIterCount(3), WithAlternate(true), WithRestrictedHashShift(restrict))
+		zyphed, _ := zy.HexZyph(v)
+
+		reslt, err := zy.HexUnzyph(zyphed)
+		if err != nil {
+			t.Fatalf("restrict %v: unexpected error %v", restrict, err)
+		}
+
+		if reslt != v {
+			t.Errorf("restrict %v: got %q, wanted %q", restrict, reslt, v)
+		}
+	}
+}
+
+func TestUnzyphNotReversible(t *testing.T) {
+	// ~ only comes from a lossless space
+	zy := NewZypher(WithShiftIterCount(1))
+	if _, err := zy.AsciUnzyph("ab~c"); !errors.Is(err, ErrNotReversible) {
+		t.Errorf("got %v, wanted ErrNotReversible", err)
+	}
+}
+
+func TestUnzyphInvalidInput(t *testing.T) {
+	zy := NewZypher(WithShiftIterCount(1))
+	if _, err := zy.AsciUnzyph("a-b"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("got %v, wanted ErrInvalidInput", err)
+	}
+	if _, err := zy.HexUnzyph("a\tb"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("got %v, wanted ErrInvalidInput", err)
+	}
+}
+
+func TestUnzyphStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	zy := NewZypher(WithShiftIterCount(MaxIterCount), WithContext(ctx))
+	if _, err := zy.AsciUnzyph("abc"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, wanted context.Canceled", err)
+	}
+}
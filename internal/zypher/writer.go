@@ -0,0 +1,136 @@
+package zypher
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"hash"
+)
+
+// Writer zyphs everything written to it so large inputs like files never have to be held in memory.
+// A stream is bytes rather than text so nothing is validated or normalized, ascii is shifted as Zyph
+// shifts it and every other byte is passed through, for ascii text Sum gives the same digest as Zyph.
+// Only the digest hashers stream, so a writer with another hasher or without a hash round fails every write
+type Writer struct {
+	zy      *Zypher
+	written int
+	// stream takes the shifted bytes for the first hash round
+	stream  hash.Hash
+	scratch []byte
+	err     error
+}
+
+var ErrWriterUnhashed = errors.New("a writer needs at least one hash round, without one its digest is as large as its input")
+
+var _ hash.Hash = (*Writer)(nil)
+
+func NewWriter(ops ...func(*Zypher)) *Writer {
+	w := &Writer{zy: NewZypher(ops...)}
+	w.Reset()
+	return w
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.scratch = append(w.scratch[:0], p...)
+	for i, b := range w.scratch {
+		if b > 0x7f {
+			continue
+		}
+
+		r := rune(b)
+		alt := w.zy.Alternate && (w.written+i)%2 != 0
+		for j := 0; j < w.zy.ShiftIterCount; j++ {
+			r = asciShift(r, w.zy.Shift, alt, w.zy.IgnoreSpace)
+		}
+		w.scratch[i] = byte(r)
+	}
+	w.written += len(p)
+	w.stream.Write(w.scratch)
+	return len(p), nil
+}
+
+// Sum appends the same hex digest Zyph returns, it leaves the writer as it is so more can be written after
+func (w *Writer) Sum(b []byte) []byte {
+	digest, err := w.appendDigest(b)
+	if err != nil {
+		return b
+	}
+	return digest
+}
+
+// Digest is Sum as a string, use it when a failed hash round has to be told apart from an empty input
+func (w *Writer) Digest() (string, error) {
+	digest, err := w.appendDigest(nil)
+	if err != nil {
+		return "", err
+	}
+	return string(digest), nil
+}
+
+func (w *Writer) appendDigest(dst []byte) ([]byte, error) {
+	if w.err != nil {
+		return dst, w.err
+	}
+
+	start := len(dst)
+	dst = hex.AppendEncode(dst, w.stream.Sum(nil))
+	return w.zy.appendHashRounds(dst, start, 1, w.zy.hashRoundCount())
+}
+
+func (w *Writer) Reset() {
+	w.written = 0
+	w.stream = nil
+	if w.err = w.zy.checkCounts(); w.err != nil {
+		return
+	}
+	if w.zy.hashRoundCount() == 0 {
+		w.err = ErrWriterUnhashed
+		return
+	}
+
+	// bcrypt salts its digest and the kdfs need all of their input at once
+	d, err := w.zy.digest()
+	if err != nil {
+		w.err = err
+		return
+	}
+
+	if key := w.zy.roundKey(0); len(key) > 0 {
+		w.stream = hmac.New(d.new, key)
+	} else {
+		w.stream = d.new()
+	}
+}
+
+// Size is the length of the hex digest, zero for a writer whose settings were rejected
+func (w *Writer) Size() int {
+	if w.stream == nil {
+		return 0
+	}
+	return 2 * w.stream.Size()
+}
+
+func (w *Writer) BlockSize() int {
+	if w.stream == nil {
+		return 1
+	}
+	return w.stream.BlockSize()
+}
+
+var errNegativeIterCount = errors.New(`invalid zypher, iter counts can not be negative`)
+
+// checkCounts is the settings half of what appendShifted checks before shifting
+func (z Zypher) checkCounts() error {
+	if z.ShiftIterCount < 0 || z.HashIterCount < 0 {
+		return errNegativeIterCount
+	}
+
+	if len(z.pepper) > 0 && z.HashIterCount == 0 {
+		return ErrPepperWithoutHash
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package zypher
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriterMatchesZyph(t *testing.T) {
+	tests := map[string][]func(*Zypher){
+		"default":      nil,
+		"alternate":    {WithAlternate(true), WithShift(-4)},
+		"pepper":       {WithPepper("server secret")},
+		"sha3":         {WithHasher(hashers[SHA3512]), WithHashIterCount(5)},
+		"ignore space": {WithIgnoreSpace(true)},
+	}
+
+	v := "Streamed input, 42 times over!"
+	for name, ops := range tests {
+		want, _ := NewZypher(ops...).Zyph(v)
+
+		w := NewWriter(ops...)
+		if w.Size() != len(want) {
+			t.Errorf("%v: got size %v before writing, wanted %v", name, w.Size(), len(want))
+		}
+		// odd chunk sizes so the alternate shift has to carry its position across writes
+		io.CopyBuffer(w, strings.NewReader(v), make([]byte, 7))
+
+		if got := string(w.Sum(nil)); got != want {
+			t.Errorf("%v: got %q, wanted %q", name, got, want)
+		}
+
+		if w.Size() != len(want) {
+			t.Errorf("%v: got size %v, wanted %v", name, w.Size(), len(want))
+		}
+	}
+}
+
+func TestWriterBinary(t *testing.T) {
+	data := bytes.Repeat([]byte{0x00, 0xff, 0x89, 'P', 'N', 'G'}, 1000)
+
+	first := NewWriter()
+	first.Write(data)
+
+	second := NewWriter()
+	second.Write(data[:1001])
+	second.Write(data[1001:])
+
+	if !bytes.Equal(first.Sum(nil), second.Sum(nil)) {
+		t.Errorf("got different digests for the same data written in pieces")
+	}
+
+	first.Reset()
+	first.Write([]byte("other"))
+	if bytes.Equal(first.Sum(nil), second.Sum(nil)) {
+		t.Errorf("got the same digest after Reset, wanted the new input hashed")
+	}
+}
+
+func TestWriterDigestHashersOnly(t *testing.T) {
+	tests := map[string]struct {
+		ops  []func(*Zypher)
+		want error
+	}{
+		"bcrypt":     {[]func(*Zypher){WithHasher(hashers[Bcrypt])}, ErrNotDeterministic},
+		"scrypt":     {[]func(*Zypher){WithHasher(hashers[Scrypt])}, ErrDigestOnly},
+		"argon2id":   {[]func(*Zypher){WithHasher(hashers[Argon2id])}, ErrDigestOnly},
+		"no hashing": {[]func(*Zypher){WithHashIterCount(0)}, ErrWriterUnhashed},
+	}
+
+	for name, tt := range tests {
+		w := NewWriter(tt.ops...)
+		if _, err := w.Write([]byte("data")); !errors.Is(err, tt.want) {
+			t.Errorf("%v: got %v, wanted %v", name, err, tt.want)
+		}
+		if _, err := w.Digest(); !errors.Is(err, tt.want) {
+			t.Errorf("%v: got %v from Digest, wanted %v", name, err, tt.want)
+		}
+		if w.Size() != 0 {
+			t.Errorf("%v: got size %v, wanted 0", name, w.Size())
+		}
+	}
+}
+
+func TestWriterInvalidSettings(t *testing.T) {
+	w := NewWriter(WithPepper("server secret"), WithHashIterCount(0))
+	if _, err := w.Write([]byte("data")); err != ErrPepperWithoutHash {
+		t.Errorf("got %v, wanted ErrPepperWithoutHash", err)
+	}
+
+	if _, err := w.Digest(); err == nil {
+		t.Errorf("got no error, wanted the settings error from Digest")
+	}
+}
@@ -1,16 +1,19 @@
 package zypher
 
 import (
+	"context"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"regexp"
-	"sync"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/Z3DRP/zportfolio-service/config"
 	zlg "github.com/Z3DRP/zportfolio-service/internal/zlogger"
+	"golang.org/x/text/unicode/norm"
 )
 
 var logfile = zlg.NewLogFile(
@@ -19,15 +22,36 @@ var logfile = zlg.NewLogFile(
 
 var logger = config.NewLogger(logfile, "trace", true, false)
 
+const defaultSaltLength = 16
+
+var (
+	ErrPepperWithoutHash = errors.New("invalid zypher, a pepper needs at least one hash iteration")
+	ErrInvalidInput      = errors.New("invalid string")
+)
+
+// compiled once, they used to be compiled on every call
+var (
+	asciValidator    = regexp.MustCompile(`^[a-zA-Z0-9 ]+$`)
+	hexValidator     = regexp.MustCompile(`^[a-fA-F0-9\s]+$`)
+	zypHashValidator = regexp.MustCompile(`^[a-zA-Z0-9!.,'"?_=\-+@#$%&()\s]+$`)
+	// the unzyph inputs also allow the lossless space marker, and hex output shifts into all of printable ascii
+	asciUnzyphValidator = regexp.MustCompile(`^[a-zA-Z0-9 ~]+$`)
+	printableValidator  = regexp.MustCompile(`^[\x20-\x7e]+$`)
+)
+
 type Zypher struct {
-	Shift             int // number of runes/digits to shift, defaults to 3
-	ShiftIterCount    int // number of iterations to be applied to value being zyphered, defaults to 3
-	HashIterCount     int // number of iterations to be hashed, defaults to 3
-	currentHashCount  int // number to be locked by mutex and keep track of current hash iterations performed
-	hashCountMtx      *sync.Mutex
-	Alternate         bool // if true when odd elements will reverse shift, defaults false
-	IgnoreSpace       bool // if true will ignore space and leave them in, defaults to false
-	RestrictHashShift bool // if true will only shift hash values within hahs digit range if false will shift digits outside hex range ie f could shift to j, default false
+	Shift             int    // number of runes/digits to shift, defaults to 3
+	ShiftIterCount    int    // number of iterations to be applied to value being zyphered, defaults to 3
+	HashIterCount     int    // number of iterations to be hashed, defaults to 3
+	Alternate         bool   // if true when odd elements will reverse shift, defaults false
+	IgnoreSpace       bool   // if true will ignore space and leave them in, defaults to false
+	RestrictHashShift bool   // if true will only shift hash values within hahs digit range if false will shift digits outside hex range ie f could shift to j, default false
+	SaltLength        int    // number of random bytes in the salt Hash generates, defaults to 16
+	Hasher            Hasher // digest used for the hash rounds, defaults to sha512
+	LosslessSpace     bool   // if true AsciZyph shifts spaces to ~ instead of x so AsciUnzyph can restore them, defaults to false
+	pepper            []byte
+	trace             *Trace
+	ctx               context.Context
 }
 
 func DefaultZops() *Zypher {
@@ -36,10 +60,10 @@ func DefaultZops() *Zypher {
 		Alternate:         false,
 		ShiftIterCount:    3,
 		HashIterCount:     3,
-		currentHashCount:  0,
-		hashCountMtx:      &sync.Mutex{},
 		IgnoreSpace:       false,
 		RestrictHashShift: false,
+		SaltLength:        defaultSaltLength,
+		Hasher:            hashers[SHA512],
 	}
 }
 
@@ -87,12 +111,85 @@ func WithRestrictedHashShift(i bool) func(*Zypher) {
 	}
 }
 
-func (z Zypher) AsciZyph(arg string) (string, error) {
-	isValidString := regexp.MustCompile(`^[a-zA-Z0-9 ]+$`).MatchString(arg)
+func WithSaltLength(length int) func(*Zypher) {
+	return func(z *Zypher) {
+		z.SaltLength = length
+	}
+}
 
-	if !isValidString {
-		InvalidString := errors.New("invalid string, only strings containing numbers, letters, and spaces are allowd")
-		return "", InvalidString
+// WithContext stops a zyph between iterations once ctx is done, the zyph then returns ctx's error
+func WithContext(ctx context.Context) func(*Zypher) {
+	return func(z *Zypher) {
+		z.ctx = ctx
+	}
+}
+
+// WithPepper keys the first hash round with a server side secret, the pepper is never written into an encoded hash
+func WithPepper(pepper string) func(*Zypher) {
+	return func(z *Zypher) {
+		z.pepper = []byte(pepper)
+	}
+}
+
+func WithLosslessSpace(l bool) func(*Zypher) {
+	return func(z *Zypher) {
+		z.LosslessSpace = l
+	}
+}
+
+func WithHasher(h Hasher) func(*Zypher) {
+	return func(z *Zypher) {
+		z.Hasher = h
+	}
+}
+
+// FromConfig builds a zypher from the zysettings config block, ops are applied after the config
+func FromConfig(cfg config.ZypherConfig, ops ...func(*Zypher)) (*Zypher, error) {
+	settings, err := ConfigOps(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewZypher(append(settings, ops...)...), nil
+}
+
+// ConfigCosts are the kdf costs set in zysettings, the ones left out are zero and keep their defaults
+func ConfigCosts(cfg config.ZypherConfig) KdfCosts {
+	return KdfCosts{
+		Time:    cfg.ArgonTime,
+		Memory:  cfg.ArgonMemory,
+		Threads: cfg.ArgonThreads,
+		N:       cfg.ScryptN,
+		Cost:    cfg.BcryptCost,
+	}
+}
+
+// ConfigOps turns the zysettings config block into options, for callers like Calibrate that take options rather than a zypher
+func ConfigOps(cfg config.ZypherConfig) ([]func(*Zypher), error) {
+	hasher, err := HasherWithCosts(cfg.Hasher, ConfigCosts(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	settings := []func(*Zypher){
+		WithShift(cfg.Shift),
+		WithShiftIterCount(cfg.ShiftCount),
+		WithHashIterCount(cfg.HashCount),
+		WithAlternate(cfg.Alternate),
+		WithIgnoreSpace(cfg.IgnSpace),
+		WithRestrictedHashShift(cfg.RestrictHash),
+		WithPepper(cfg.Pepper),
+		WithHasher(hasher),
+	}
+
+	if cfg.SaltLength > 0 {
+		settings = append(settings, WithSaltLength(cfg.SaltLength))
+	}
+	return settings, nil
+}
+
+func (z Zypher) AsciZyph(arg string) (string, error) {
+	if !asciValidator.MatchString(arg) {
+		return "", fmt.Errorf("%w, only strings containing numbers, letters, and spaces are allowd", ErrInvalidInput)
 	}
 
 	if z.ShiftIterCount <= 0 {
@@ -100,133 +197,210 @@ func (z Zypher) AsciZyph(arg string) (string, error) {
 		return "", MissingShiftIterCount
 	}
 
-	result := make([]rune, len(arg))
-	var wg sync.WaitGroup
+	shiftFn := asciShift
+	if z.LosslessSpace && !z.IgnoreSpace {
+		shiftFn = losslessAsciShift
+	}
 
-	for i := 0; i < z.ShiftIterCount; i++ {
-		for indx, r := range arg {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				alt := false
-				if z.Alternate {
-					alt = indx%2 != 0
-				}
-				asciShift(&result[indx], r, z.Shift, alt, z.IgnoreSpace)
-			}()
-		}
-		wg.Wait()
-		arg = string(result)
+	buf := []byte(arg)
+	if err := z.shiftBytes(buf, shiftFn); err != nil {
+		return "", err
 	}
-	//wg.Wait()
-	return string(result), nil
+	return string(buf), nil
 }
 
 func (z Zypher) HexZyph(arg string) (string, error) {
-	isValidString := regexp.MustCompile(`^[a-fA-F0-9\s]+$`).MatchString(arg)
-	if !isValidString {
-		InvalidString := errors.New("invalid hex string, only A-F, a-f, and 0-9 allowed")
-		return "", InvalidString
+	if !hexValidator.MatchString(arg) {
+		return "", fmt.Errorf("%w, only hex digits A-F, a-f, and 0-9 are allowed", ErrInvalidInput)
 	}
 
 	if z.ShiftIterCount <= 0 {
 		MissingShiftIterCount := errors.New(`invalid zypher, shift iter count expected but not found`)
 		return "", MissingShiftIterCount
 	}
-	// TODO HexZyph takes a hash then shifts it shifts for each shiftItercount
-	result := make([]rune, len(arg))
-	var wg sync.WaitGroup
 
-	for i := 0; i < z.ShiftIterCount; i++ {
-		for indx, r := range arg {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				alt := false
-				if z.Alternate {
-					alt = indx%2 != 0
-				}
-				if z.RestrictHashShift {
-					hexShift(&result[indx], r, z.Shift, alt, z.IgnoreSpace)
-				}
-				if !z.RestrictHashShift {
-					shift(&result[indx], r, z.Shift, alt, z.IgnoreSpace)
-				}
-			}()
-		}
-		wg.Wait()
-		arg = string(result)
+	shiftFn := shift
+	if z.RestrictHashShift {
+		shiftFn = hexShift
 	}
-	// wg.Wait()
-	return string(result), nil
+
+	buf := []byte(arg)
+	if err := z.shiftBytes(buf, shiftFn); err != nil {
+		return "", err
+	}
+	return string(buf), nil
 }
 
 func (z Zypher) Zyph(arg string) (string, error) {
-	isValidString := regexp.MustCompile(`^[a-zA-Z0-9!.,'"?_=\-+@#$%&()\s]+$`).MatchString(arg)
-	if !isValidString {
-		InvalidString := errors.New(`invalid string, only strings containing alpha numerics, spaces, and symbols ! . , ' " ? _ = - + @ # $ % & () are allowed`)
-		return "", InvalidString
+	digest, err := z.AppendZyph(nil, []byte(arg))
+	if err != nil {
+		return "", err
 	}
+	return string(digest), nil
+}
+
+// AppendZyph appends the Zyph digest of src to dst. ascii input hashed with sha256, sha512, sha3-512 or blake2b
+// and no pepper allocates nothing once dst has room for the shifted input and the digest
+func (z Zypher) AppendZyph(dst, src []byte) ([]byte, error) {
+	start := len(dst)
+	dst, err := z.appendShifted(dst, src)
+	if err != nil {
+		return dst[:start], err
+	}
+
+	dst, err = z.appendHashRounds(dst, start, 0, z.hashRoundCount())
+	if err != nil {
+		return dst[:start], err
+	}
+	return dst, nil
+}
 
+// appendShifted validates src and appends it to dst with the shift iterations applied, it is the part of Zyph before any hashing
+func (z Zypher) appendShifted(dst, src []byte) ([]byte, error) {
 	if z.ShiftIterCount < 0 {
 		MissingShiftIterCount := errors.New(`invalid zypher, shift iter count expected but not found`)
-		return "", MissingShiftIterCount
+		return dst, MissingShiftIterCount
 	}
 
 	if z.HashIterCount < 0 {
 		MissingHashIterCount := errors.New(`invalid zypher, hash iter count expected but not found`)
-		return "", MissingHashIterCount
+		return dst, MissingHashIterCount
+	}
+
+	if len(z.pepper) > 0 && z.HashIterCount == 0 {
+		return dst, ErrPepperWithoutHash
+	}
+
+	// ascii needs no normalizing and shifts a byte at a time, anything else goes through runes
+	if isZyphableAscii(src) {
+		start := len(dst)
+		dst = append(dst, src...)
+		if err := z.shiftBytes(dst[start:], asciShift); err != nil {
+			return dst[:start], err
+		}
+		return dst, nil
+	}
+
+	arg := string(src)
+	if !isZyphable(arg) {
+		return dst, fmt.Errorf("%w, only valid utf-8 made of printable characters and spaces is allowed", ErrInvalidInput)
+	}
+	// the same text can be typed as composed or decomposed runes, normalizing makes both give the same digest
+	runes := []rune(norm.NFC.String(arg))
+	if err := z.shiftRunes(runes, asciShift); err != nil {
+		return dst, err
 	}
+	for _, r := range runes {
+		dst = utf8.AppendRune(dst, r)
+	}
+	return dst, nil
+}
 
-	// TODO Zyph shifts string then hashes it foreach shiftItercount and foreach hashItercount
-	var result = make([]rune, len(arg))
-	logger.MustDebug(fmt.Sprintf("arg size: %v; result size: %v", len(arg), len(result)))
-	var wg sync.WaitGroup
+type shiftFunc func(r rune, shf int, alt, ignSpc bool) rune
 
+// shiftBytes applies every shift iteration to ascii buf in place, each shift function keeps ascii input ascii
+func (z Zypher) shiftBytes(buf []byte, shiftFn shiftFunc) error {
 	for i := 0; i < z.ShiftIterCount; i++ {
-		for indx, r := range arg {
-			wg.Add(1)
-			go func(indx int, r rune) {
-				defer wg.Done()
-				if indx >= len(result) {
-					return
-				}
-				alt := false
-				if z.Alternate {
-					alt = indx%2 != 0
-				}
-
-				asciShift(&result[indx], r, z.Shift, alt, z.IgnoreSpace)
-			}(indx, r)
+		if err := z.canceled(); err != nil {
+			return err
 		}
+		start := z.stepStart()
+		for indx, b := range buf {
+			buf[indx] = byte(shiftFn(rune(b), z.Shift, z.Alternate && indx%2 != 0, z.IgnoreSpace))
+		}
+		z.recordBytes(StageShift, i, buf, start)
+	}
+	return nil
+}
 
-		wg.Wait()
-		arg = string(result)
-		// TODO tweak this so hash iterations dont get missed for now moved outside of this loop and just hash for hash count
-		// z.hashCountMtx.Lock()
-		// if z.currentHashCount <= z.HashIterCount {
-		// 	hsh := sha512.New()
-		// 	hsh.Write([]byte(string(result)))
-		// 	bs := hsh.Sum(nil)
-		// 	arg = hex.EncodeToString(bs)
-		// 	z.currentHashCount++
-		// }
-		// z.hashCountMtx.Unlock()
+func (z Zypher) shiftRunes(runes []rune, shiftFn shiftFunc) error {
+	for i := 0; i < z.ShiftIterCount; i++ {
+		if err := z.canceled(); err != nil {
+			return err
+		}
+		start := z.stepStart()
+		for indx, r := range runes {
+			runes[indx] = shiftFn(r, z.Shift, z.Alternate && indx%2 != 0, z.IgnoreSpace)
+		}
+		z.recordRunes(StageShift, i, runes, start)
 	}
+	return nil
+}
 
-	for i := 0; i < z.HashIterCount; i++ {
-		hsh := sha512.New()
-		hsh.Write([]byte(arg))
-		bs := hsh.Sum(nil)
-		arg = hex.EncodeToString(bs)
+// appendHashRounds hashes dst[start:] in place for rounds first to rounds-1, each round hashes the hex digest of the one before it
+func (z Zypher) appendHashRounds(dst []byte, start, first, rounds int) ([]byte, error) {
+	hasher := z.hasher()
+	for i := first; i < rounds; i++ {
+		if err := z.canceled(); err != nil {
+			return dst, err
+		}
+		stepStart := z.stepStart()
+		var err error
+		dst, err = appendSum(hasher, dst, start, z.roundKey(i))
+		if err != nil {
+			return dst, fmt.Errorf("%v hash round failed:: %w", hasher.Name(), err)
+		}
+		z.recordBytes(StageHash, i, dst[start:], stepStart)
 	}
-	return arg, nil
+	return dst, nil
 }
 
-func (z Zypher) ZypHash(arg string) (string, error) {
-	isValidString := regexp.MustCompile(`^[a-zA-Z0-9!.,'"?_=\-+@#$%&()\s]+$`).MatchString(arg)
+// canceled is checked before every iteration, a zypher without a context never stops early
+func (z Zypher) canceled() error {
+	if z.ctx == nil {
+		return nil
+	}
+	return z.ctx.Err()
+}
 
-	if !isValidString {
+// verifyDigest checks plaintext against a Zyph digest, the last round goes through the hasher's Verify so salted hashers like bcrypt work
+func (z Zypher) verifyDigest(plaintext, digest string) (bool, error) {
+	shifted, err := z.appendShifted(nil, []byte(plaintext))
+	if err != nil {
+		return false, err
+	}
+
+	rounds := z.hashRoundCount()
+	if rounds == 0 {
+		return subtle.ConstantTimeCompare(shifted, []byte(digest)) == 1, nil
+	}
+
+	prev, err := z.appendHashRounds(shifted, 0, 0, rounds-1)
+	if err != nil {
+		return false, err
+	}
+
+	sum, err := hex.DecodeString(digest)
+	if err != nil {
+		return false, nil
+	}
+	return z.hasher().Verify(prev, z.roundKey(rounds-1), sum)
+}
+
+func (z Zypher) hasher() Hasher {
+	if z.Hasher == nil {
+		return hashers[SHA512]
+	}
+	return z.Hasher
+}
+
+func (z Zypher) hashRoundCount() int {
+	if z.HashIterCount > 1 && !z.hasher().Iterative() {
+		return 1
+	}
+	return z.HashIterCount
+}
+
+// roundKey only hands the pepper to the first round
+func (z Zypher) roundKey(round int) []byte {
+	if round == 0 {
+		return z.pepper
+	}
+	return nil
+}
+
+func (z Zypher) ZypHash(arg string) (string, error) {
+	if !zypHashValidator.MatchString(arg) {
 		InvalidString := errors.New(`invalid string, only strings containing alpha numerics, spaces, and symbols ! . , ' " ? _ = - + @ # $ % & () are allowed`)
 		return " ", InvalidString
 	}
@@ -244,7 +418,7 @@ func (z Zypher) ZypHash(arg string) (string, error) {
 	return result, nil
 }
 
-func asciShift(target *rune, r rune, shf int, alt bool, ignSpc bool) {
+func asciShift(r rune, shf int, alt bool, ignSpc bool) rune {
 	switch alt {
 	case true:
 		if shf > 0 {
@@ -255,25 +429,35 @@ func asciShift(target *rune, r rune, shf int, alt bool, ignSpc bool) {
 	}
 
 	if r >= '0' && r <= '9' {
-		*target = '0' + (r-'0'+rune(shf)+10)%10
+		return '0' + (r-'0'+rune(shf)+10)%10
 	} else if r >= 'A' && r <= 'Z' {
-		*target = 'A' + (r-'A'+rune(shf)+26)%26
+		return 'A' + (r-'A'+rune(shf)+26)%26
 
 	} else if r >= 'a' && r <= 'z' {
-		*target = 'a' + (r-'a'+rune(shf)+26)%26
+		return 'a' + (r-'a'+rune(shf)+26)%26
 	} else if r == ' ' {
 		if !ignSpc {
-			*target = 'x'
-			// *target = 32 + (r-32+rune(shf)+95)%95
+			return 'x'
+			// return 32 + (r-32+rune(shf)+95)%95
 		} else {
-			*target = ' '
+			return ' '
 		}
+	} else if r > unicode.MaxASCII {
+		return categoryShift(r, shf, ignSpc)
 	} else {
-		*target = r
+		return r
 	}
 }
 
-func hexShift(target *rune, r rune, shf int, alt, ignSpc bool) {
+// losslessAsciShift shifts a space to a marker AsciUnzyph can turn back into a space
+func losslessAsciShift(r rune, shf int, alt bool, ignSpc bool) rune {
+	if r == ' ' {
+		return spaceMarker
+	}
+	return asciShift(r, shf, alt, ignSpc)
+}
+
+func hexShift(r rune, shf int, alt, ignSpc bool) rune {
 	switch alt {
 	case true:
 		if shf > 0 {
@@ -284,21 +468,23 @@ func hexShift(target *rune, r rune, shf int, alt, ignSpc bool) {
 	}
 
 	if r >= '0' && r <= '9' {
-		*target = '0' + (r-'0'+rune(shf)+10)%10
+		return '0' + (r-'0'+rune(shf)+10)%10
 	} else if r >= 'a' && r <= 'f' {
-		*target = 'a' + (r-'a'+rune(shf)+6)%6
+		return 'a' + (r-'a'+rune(shf)+6)%6
 	} else if r >= 'A' && r <= 'F' {
-		*target = 'A' + (r-'A'+rune(shf)+6)%6
+		return 'A' + (r-'A'+rune(shf)+6)%6
 	} else if r >= 32 && r <= 126 {
 		if r == 32 && ignSpc {
-			*target = r
+			return r
 		} else {
-			*target = 32 + (r-32+rune(shf)+95)%95
+			return 32 + (r-32+rune(shf)+95)%95
 		}
+	} else {
+		return r
 	}
 }
 
-func shift(target *rune, r rune, shf int, alt, ignSpc bool) {
+func shift(r rune, shf int, alt, ignSpc bool) rune {
 	switch alt {
 	case true:
 		if shf > 0 {
@@ -308,17 +494,22 @@ func shift(target *rune, r rune, shf int, alt, ignSpc bool) {
 		}
 	}
 
-	if unicode.IsDigit(r) {
-		*target = '0' + (r-'0'+rune(shf)+10)%10
-	} else if unicode.IsUpper(r) {
-		*target = 'A' + (r-'A'+rune(shf)+26)%26
-	} else if unicode.IsLower(r) {
-		*target = 'a' + (r-'a'+rune(shf)+26)%26
+	// the unicode.Is checks matched letters and digits from every script but the arithmetic only works for ascii
+	if r >= '0' && r <= '9' {
+		return '0' + (r-'0'+rune(shf)+10)%10
+	} else if r >= 'A' && r <= 'Z' {
+		return 'A' + (r-'A'+rune(shf)+26)%26
+	} else if r >= 'a' && r <= 'z' {
+		return 'a' + (r-'a'+rune(shf)+26)%26
 	} else if r >= 32 && r <= 126 {
 		if r == 32 && ignSpc {
-			*target = r
+			return r
 		} else {
-			*target = 32 + (r-32+rune(shf)+95)%95
+			return 32 + (r-32+rune(shf)+95)%95
 		}
+	} else if r > unicode.MaxASCII {
+		return categoryShift(r, shf, ignSpc)
+	} else {
+		return r
 	}
 }
@@ -1,6 +1,10 @@
 package zypher
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+)
 
 func TestAsciZyphNoIgnoreSpace(t *testing.T) {
 	v := "Abc Z19"
@@ -101,6 +105,32 @@ func TestCustomZyph(t *testing.T) {
 	}
 }
 
+func TestZyphStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	zy := NewZypher(WithContext(ctx), WithShiftIterCount(MaxIterCount), WithHashIterCount(MaxIterCount))
+	for name, zyph := range map[string]func(string) (string, error){"Zyph": zy.Zyph, "AsciZyph": zy.AsciZyph, "HexZyph": zy.HexZyph} {
+		if _, err := zyph("abc123"); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s got %v, wanted %v", name, err, context.Canceled)
+		}
+	}
+
+	// with no shift iterations it is the hash rounds that have to stop
+	zy = NewZypher(WithContext(ctx), WithShiftIterCount(0), WithHashIterCount(MaxIterCount))
+	if _, err := zy.Zyph("abc123"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, wanted %v", err, context.Canceled)
+	}
+
+	got, err := NewZypher(WithContext(context.Background())).Zyph("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := NewZypher().Zyph("abc123"); got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
+
 func wantZyph(shf, shfItr, hshItr int, alt, ign bool) struct {
 	shift        int
 	shfIterCount int
@@ -0,0 +1,8 @@
+// loadZypher starts the zypher wasm build and resolves with its exported functions,
+// wasm_exec.js from the same directory has to be loaded first
+async function loadZypher(base = "/zypher/wasm/") {
+	const go = new Go();
+	const { instance } = await WebAssembly.instantiateStreaming(fetch(base + "zypher.wasm"), go.importObject);
+	go.run(instance);
+	return globalThis.zypher;
+}